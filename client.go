@@ -2,7 +2,8 @@ package multiping
 
 import "github.com/drgkaleda/go-multiping/pingdata"
 
-// Unified interface to process ping data
+// Unified interface to process ping data.
+// Register client in Scheduler to get results of periodic pings.
 type PingClient interface {
 	PingProcess(pr *pingdata.PingData)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -23,16 +24,17 @@ const (
 
 var verbose = logLevelNone
 var count = 5
+var probes = 1
 
 func doPing(data *pingdata.PingData) error {
-	// First try privileged
-	mp, err := multiping.New(true)
+	// Library chooses privileged or non privileged mode itself
+	mp, err := multiping.NewWithOptions(multiping.WithCount(probes))
 	if err != nil {
-		// if failed - try fallback to non privileged
-		mp, err = multiping.New(false)
-		if err != nil {
-			return nil
-		}
+		return err
+	}
+
+	if verbose != logLevelNone {
+		fmt.Println("Ping", mp.Detection())
 	}
 
 	fmt.Println("Ping results:")
@@ -41,7 +43,10 @@ func doPing(data *pingdata.PingData) error {
 	}
 
 	for i := 0; i < count; i++ {
-		mp.Ping(data)
+		_, err = mp.PingContext(context.Background(), data)
+		if err != nil {
+			fmt.Println("Ping error:", err)
+		}
 
 		var latencySum float32
 		var lossCount uint
@@ -64,7 +69,7 @@ func doPing(data *pingdata.PingData) error {
 			switch verbose {
 			case logLevelFull:
 				var additionalInfo string
-				if !val.Valid() || val.Duplicate() > 0 {
+				if !val.Valid() || val.Duplicate() > 0 || val.Errors() > 0 || val.Corrupted() > 0 || val.Truncated() > 0 {
 					additionalInfo = "("
 					if !val.Valid() {
 						additionalInfo = additionalInfo + " invalid "
@@ -72,6 +77,12 @@ func doPing(data *pingdata.PingData) error {
 					if val.Duplicate() > 0 {
 						additionalInfo = additionalInfo + fmt.Sprintf(" dupps=%d ", val.Duplicate())
 					}
+					if val.Corrupted() > 0 || val.Truncated() > 0 {
+						additionalInfo = additionalInfo + fmt.Sprintf(" corrupted=%d truncated=%d ", val.Corrupted(), val.Truncated())
+					}
+					if typ, code, router, ok := val.LastError(); ok {
+						additionalInfo = additionalInfo + fmt.Sprintf(" icmp error %d/%d from %s ", typ, code, router)
+					}
 				}
 				fmt.Printf("%16s\t%fms\t%f%%\t%s\n",
 					ip, val.Latency(), val.Loss()*100, additionalInfo)
@@ -103,6 +114,7 @@ func doPing(data *pingdata.PingData) error {
 func main() {
 	fileName := flag.String("f", "", "File with IP list")
 	flag.IntVar(&count, "c", 5, "Stop after sending count pings")
+	flag.IntVar(&probes, "C", 1, "Echo requests sent to every host in a single ping")
 	flag.IntVar(&verbose, "v", logLevelNone, "Verbose logging level [0|1|2]")
 
 	flag.Parse()
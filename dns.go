@@ -0,0 +1,259 @@
+package multiping
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// dnsPort is port of DNS probes, unless ProbeOptions.Port is set
+const dnsPort = 53
+
+// DNSQuery is a question sent by DNS probes
+type DNSQuery struct {
+	Name string          // queried domain name, e.g. "example.com"
+	Type dnsmessage.Type // query type, TypeA when zero
+}
+
+// DNSReply is an answer to DNS probe
+type DNSReply struct {
+	Rcode   dnsmessage.RCode // response code
+	Answers int              // count of answer records
+}
+
+// PingDNS probes hosts in data with DNS query over UDP instead of echo requests.
+// Answer with any response code counts as reply, the code is stored in data, see PingStats.Rcode.
+// Queries go to port 53, use RoundConfig.DNS with ProbeOptions.Port for other ports.
+func (mp *MultiPing) PingDNS(ctx context.Context, data *pingdata.PingData, query DNSQuery) (RoundSummary, error) {
+	cfg := mp.Config()
+	cfg.DNS = &query
+	return mp.PingConfig(ctx, data, cfg)
+}
+
+// question returns DNS question of query
+func (q *DNSQuery) question() (dnsmessage.Question, error) {
+	name := q.Name
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return dnsmessage.Question{}, err
+	}
+
+	typ := q.Type
+	if typ == 0 {
+		typ = dnsmessage.TypeA
+	}
+	return dnsmessage.Question{Name: n, Type: typ, Class: dnsmessage.ClassINET}, nil
+}
+
+// packQuery builds recursive DNS query message with id
+func packQuery(question dnsmessage.Question, id uint16) ([]byte, error) {
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{ID: id, RecursionDesired: true})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(question); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// validate checks, that query can be sent
+func (q *DNSQuery) validate() error {
+	question, err := q.question()
+	if err == nil {
+		_, err = packQuery(question, 0)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: DNS query %q: %v", ErrInvalidOption, q.Name, err)
+	}
+	return nil
+}
+
+// dnsProber sends DNS queries from one UDP socket per address family.
+// Query ID is probe sequence, so answers are matched by ID and question.
+type dnsProber struct {
+	cfg      *RoundConfig
+	question dnsmessage.Question
+	conn4    *net.UDPConn
+	conn6    *net.UDPConn
+
+	mu   sync.Mutex
+	sent map[probeKey]time.Time
+
+	packets chan *pinger.Packet
+	done    chan struct{}
+	wg      sync.WaitGroup // reader goroutines
+}
+
+func (mp *MultiPing) newDNSProber(cfg *RoundConfig, sess *session) (*dnsProber, error) {
+	question, err := cfg.DNS.question()
+	if err != nil {
+		return nil, err
+	}
+	p := &dnsProber{
+		cfg:      cfg,
+		question: question,
+		sent:     make(map[probeKey]time.Time),
+		packets:  make(chan *pinger.Packet),
+		done:     make(chan struct{}),
+	}
+
+	// Sockets of the same families as ICMP session
+	if sess.conn4 != nil {
+		if p.conn4, err = listenUDP("udp4", mp.listen4.Source); err != nil {
+			return nil, err
+		}
+	}
+	if sess.conn6 != nil {
+		if p.conn6, err = listenUDP("udp6", mp.listen6.Source); err != nil {
+			if p.conn4 != nil {
+				p.conn4.Close()
+			}
+			return nil, err
+		}
+	}
+
+	for _, conn := range []*net.UDPConn{p.conn4, p.conn6} {
+		if conn != nil {
+			p.wg.Add(1)
+			go p.read(conn)
+		}
+	}
+	return p, nil
+}
+
+// listenUDP opens UDP socket bound to source, if it is valid
+func listenUDP(network string, source netip.Addr) (*net.UDPConn, error) {
+	addr := &net.UDPAddr{}
+	if source.IsValid() {
+		addr.IP = source.AsSlice()
+	}
+	return net.ListenUDP(network, addr)
+}
+
+// port returns port of DNS server addr
+func (p *dnsProber) port(addr netip.Addr) uint16 {
+	if port := p.cfg.probe(addr).Port; port != 0 {
+		return uint16(port)
+	}
+	return dnsPort
+}
+
+func (p *dnsProber) Prepare(addr netip.Addr, seq uint16, opts ProbeOptions) (*pinger.Packet, error) {
+	b, err := packQuery(p.question, seq)
+	if err != nil {
+		return nil, err
+	}
+	return &pinger.Packet{Addr: addr, Seq: seq, Bytes: b, Len: len(b)}, nil
+}
+
+func (p *dnsProber) Send(pkt *pinger.Packet) error {
+	conn := p.conn4
+	if pkt.Addr.Is6() {
+		conn = p.conn6
+	}
+	if conn == nil {
+		return ErrUnsupportedFamily
+	}
+
+	p.mu.Lock()
+	p.sent[probeKey{addr: pkt.Addr, seq: pkt.Seq}] = time.Now()
+	p.mu.Unlock()
+
+	_, err := conn.WriteToUDPAddrPort(pkt.Bytes, netip.AddrPortFrom(pkt.Addr, p.port(pkt.Addr)))
+	return err
+}
+
+// read reads answers of DNS servers
+func (p *dnsProber) read(conn *net.UDPConn) {
+	defer p.wg.Done()
+
+	local := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+	b := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFromUDPAddrPort(b)
+		if err != nil {
+			return
+		}
+		addr := from.Addr().Unmap()
+		if from.Port() != p.port(addr) {
+			continue
+		}
+
+		pkt := &pinger.Packet{Addr: addr, Port: local, Bytes: append([]byte(nil), b[:n]...), Len: n}
+		select {
+		case p.packets <- pkt:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *dnsProber) Receive() (*pinger.Packet, error) {
+	select {
+	case pkt := <-p.packets:
+		return pkt, nil
+	case <-p.done:
+		return nil, ErrProberClosed
+	}
+}
+
+// Match checks, whether DNS message answers query
+func (p *dnsProber) Match(pkt *pinger.Packet) (ProbeResult, bool) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(pkt.Bytes)
+	if err != nil || !header.Response {
+		return ProbeResult{}, false
+	}
+	q, err := parser.Question()
+	if err != nil || q.Type != p.question.Type || q.Class != p.question.Class ||
+		!strings.EqualFold(q.Name.String(), p.question.Name.String()) {
+		return ProbeResult{}, false
+	}
+
+	reply := &DNSReply{Rcode: header.RCode}
+	if err = parser.SkipAllQuestions(); err == nil {
+		for {
+			if _, err = parser.AnswerHeader(); err != nil {
+				break
+			}
+			if err = parser.SkipAnswer(); err != nil {
+				break
+			}
+			reply.Answers++
+		}
+	}
+
+	res := ProbeResult{Kind: ProbeReply, Addr: pkt.Addr, Seq: header.ID, TOS: -1, DNS: reply}
+	key := probeKey{addr: pkt.Addr, seq: header.ID}
+	p.mu.Lock()
+	if sent, ok := p.sent[key]; ok {
+		res.RTT = time.Since(sent)
+		delete(p.sent, key)
+	}
+	p.mu.Unlock()
+	return res, true
+}
+
+func (p *dnsProber) Close() {
+	close(p.done)
+	if p.conn4 != nil {
+		p.conn4.Close()
+	}
+	if p.conn6 != nil {
+		p.conn6.Close()
+	}
+	p.wg.Wait()
+}
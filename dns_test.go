@@ -0,0 +1,120 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+)
+
+// stubResolver answers A queries of example.com and NXDOMAIN to anything else
+func stubResolver(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Listen failed: %s", err)
+	}
+
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFromUDP(b)
+			if err != nil {
+				return
+			}
+
+			var parser dnsmessage.Parser
+			header, err := parser.Start(b[:n])
+			if err != nil {
+				continue
+			}
+			q, err := parser.Question()
+			if err != nil {
+				continue
+			}
+
+			header.Response = true
+			known := strings.EqualFold(q.Name.String(), "example.com.")
+			if !known {
+				header.RCode = dnsmessage.RCodeNameError
+			}
+			builder := dnsmessage.NewBuilder(nil, header)
+			builder.StartQuestions()
+			builder.Question(q)
+			if known {
+				builder.StartAnswers()
+				builder.AResource(dnsmessage.ResourceHeader{Name: q.Name, Class: q.Class, TTL: 60},
+					dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}})
+			}
+			if msg, err := builder.Finish(); err == nil {
+				conn.WriteToUDP(msg, from)
+			}
+		}
+	}()
+	return conn
+}
+
+func TestPingDNS(t *testing.T) {
+	conn := stubResolver(t)
+	defer conn.Close()
+
+	mp, err := NewWithOptions(WithPrivileged(false), WithTimeout(300*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	resolver := netip.MustParseAddr("127.0.0.1")
+	silent := netip.MustParseAddr("127.0.0.2")
+
+	cfg := mp.Config()
+	cfg.Count = 2
+	cfg.Interval = 50 * time.Millisecond
+	cfg.Probe.Port = conn.LocalAddr().(*net.UDPAddr).Port
+
+	for name, rcode := range map[string]dnsmessage.RCode{"example.com": dnsmessage.RCodeSuccess, "missing.example.com": dnsmessage.RCodeNameError} {
+		data := pingdata.NewPingData()
+		data.Add(resolver, silent)
+
+		cfg.DNS = &DNSQuery{Name: name}
+		summary, err := mp.PingConfig(context.Background(), data, cfg)
+		if err != nil {
+			t.Fatalf("%s: DNS ping failed: %s", name, err)
+		}
+		if summary.Sent != 4 || summary.Received != 2 {
+			t.Errorf("%s: invalid summary %+v", name, summary)
+		}
+
+		stats, _ := data.Get(resolver)
+		code, ok := stats.Rcode()
+		if !ok || code != int(rcode) || stats.Loss() != 0 || stats.Rtt() <= 0 {
+			t.Errorf("%s: resolver rcode %d, loss %f, rtt %s", name, code, stats.Loss(), stats.Rtt())
+		}
+		if rcode != dnsmessage.RCodeSuccess && stats.RcodeErrors() != 2 {
+			t.Errorf("%s: expected 2 rcode errors, got %d", name, stats.RcodeErrors())
+		}
+		if stats, _ = data.Get(silent); stats.Loss() != 1 {
+			t.Errorf("%s: silent host loss %f", name, stats.Loss())
+		}
+	}
+
+	// Answers are streamed with response code
+	cfg.DNS = &DNSQuery{Name: "example.com", Type: dnsmessage.TypeA}
+	for res := range mp.StreamConfig(context.Background(), []netip.Addr{resolver}, cfg) {
+		if res.Kind != ProbeReply || res.DNS == nil || res.DNS.Rcode != dnsmessage.RCodeSuccess || res.DNS.Answers != 1 {
+			t.Errorf("Invalid streamed result %+v", res)
+		}
+	}
+
+	cfg.DNS = &DNSQuery{Name: strings.Repeat("a", 64) + ".example.com"}
+	data := pingdata.NewPingData()
+	data.Add(resolver)
+	if _, err = mp.PingConfig(context.Background(), data, cfg); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected invalid option error, got %v", err)
+	}
+}
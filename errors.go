@@ -0,0 +1,24 @@
+package multiping
+
+import "errors"
+
+var (
+	// ErrInvalidOption is returned by NewWithOptions, when option value is not valid
+	ErrInvalidOption = errors.New("invalid option")
+
+	// ErrSocketSetup is returned when ICMP sockets could not be opened or configured
+	ErrSocketSetup = errors.New("socket setup failed")
+
+	// ErrUnsupportedFamily is returned when some hosts could not be pinged,
+	// because their address family has no working socket (e.g. IPv6 is disabled)
+	ErrUnsupportedFamily = errors.New("unsupported address family")
+
+	// ErrPartialSend is returned when some of echo requests could not be sent
+	ErrPartialSend = errors.New("partial send failure")
+
+	// ErrPrivilegedOnly is returned by features, which need raw ICMP sockets
+	ErrPrivilegedOnly = errors.New("privileged mode required")
+
+	// ErrProberClosed is returned by Prober Receive, when prober is closed
+	ErrProberClosed = errors.New("prober is closed")
+)
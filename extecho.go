@@ -0,0 +1,40 @@
+package multiping
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// PingInterfaces asks targets about state of interface identified by query with ICMP extended echo
+// requests (RFC 8335) and returns the last reply of every target, which answered. Reply code other than
+// pinger.ExtendedNoError means, that target answered, but interface was not found.
+// MultiPing Count must not exceed 256, as extended echo has 8 bit sequence.
+func (mp *MultiPing) PingInterfaces(ctx context.Context, targets []netip.Addr, query pinger.InterfaceQuery) (map[netip.Addr]*pinger.ExtendedEcho, error) {
+	cfg := mp.Config()
+	cfg.Interface = &query
+	if err := mp.check(&cfg); err != nil {
+		return nil, err
+	}
+
+	replies := make(map[netip.Addr]*pinger.ExtendedEcho, len(targets))
+	failed := 0
+	for res := range mp.StreamConfig(ctx, targets, cfg) {
+		switch res.Kind {
+		case ProbeReply:
+			replies[res.Addr] = res.Extended
+		case ProbeSendError:
+			failed++
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if failed > 0 {
+		return replies, fmt.Errorf("%w: %d requests failed", ErrPartialSend, failed)
+	}
+	return replies, nil
+}
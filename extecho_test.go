@@ -0,0 +1,131 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+func TestRegisterShort(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+
+	// Echo round holds some sequences below 256
+	mp.sequence = 65500
+	echo := mp.register(nil, 100)
+	first, ok := mp.registerShort(nil, 10)
+	if !ok || first < uint16(echo+100) || first+10 > maxShortSeqs {
+		t.Errorf("Short sequences %d overlap echo sequences %d", first, echo)
+	}
+	// Following echo rounds skip short sequences
+	mp.sequence = first
+	if seq := mp.register(nil, 1); seq == first {
+		t.Errorf("Short sequence %d reused", seq)
+	}
+
+	if _, ok = mp.registerShort(nil, maxShortSeqs); ok {
+		t.Error("Busy short sequences reserved")
+	}
+}
+
+func TestPingInterfaces(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	local := netip.MustParseAddr("127.0.0.1")
+
+	_, err = mp.PingInterfaces(context.Background(), []netip.Addr{local}, pinger.InterfaceQuery{})
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected invalid option error for empty query, got %v", err)
+	}
+
+	b, err := os.ReadFile("/proc/sys/net/ipv4/icmp_echo_enable_probe")
+	if err != nil || strings.TrimSpace(string(b)) != "1" {
+		t.Skip("Extended echo is not answered, see net.ipv4.icmp_echo_enable_probe")
+	}
+
+	replies, err := mp.PingInterfaces(context.Background(), []netip.Addr{local}, pinger.InterfaceQuery{Name: "lo"})
+	if err != nil {
+		t.Fatalf("Extended echo failed: %s", err)
+	}
+	if reply := replies[local]; reply == nil || !reply.Found() || !reply.Active || !reply.IPv4 {
+		t.Errorf("Unexpected loopback state %v", reply)
+	}
+}
+
+func TestExtendedEchoMatch(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	router := netip.MustParseAddr("192.0.2.1")
+	cfg := &RoundConfig{Interface: &pinger.InterfaceQuery{Name: "eth0"}}
+	p := mp.newICMPProber(cfg)
+	seq, ok := mp.registerShort(p, 1)
+	if !ok {
+		t.Fatal("No free short sequence")
+	}
+	defer mp.unregister(seq, 1)
+
+	pkt, err := p.Prepare(router, seq, ProbeOptions{})
+	if err != nil {
+		t.Fatalf("Extended echo prepare failed: %s", err)
+	}
+	p.sent[probeKey{addr: router, seq: pkt.Seq}] = time.Now().Add(-time.Millisecond)
+
+	// RFC 8335 reply of router
+	reply := func(typ icmp.Type, code int, body icmp.MessageBody) *pinger.Packet {
+		m := icmp.Message{Type: typ, Code: code, Body: body}
+		b, err := m.Marshal(nil)
+		if err != nil {
+			t.Fatalf("Marshal reply: %s", err)
+		}
+		return &pinger.Packet{Proto: pinger.ProtocolIpv4, Bytes: b, Len: len(b), Addr: router, TTL: 64, TOS: -1}
+	}
+
+	// Reply has no tracker, session routes it to prober anyway
+	sess := &session{rxChan: make(chan *pinger.Packet), rxDone: make(chan struct{})}
+	go mp.batchProcessPacket(sess)
+	go func() {
+		sess.rxChan <- reply(ipv4.ICMPTypeExtendedEchoReply, pinger.ExtendedNoError,
+			&icmp.ExtendedEchoReply{Seq: int(seq), Active: true, IPv4: true})
+		close(sess.rxChan)
+		<-sess.rxDone
+		p.Close()
+	}()
+	routed, err := p.Receive()
+	if err != nil {
+		t.Fatalf("Extended echo reply not routed: %s", err)
+	}
+
+	res, ok := p.Match(routed)
+	if !ok || res.Kind != ProbeReply || res.Addr != router || res.Seq != seq || res.RTT < time.Millisecond {
+		t.Fatalf("Extended echo reply not matched: %+v", res)
+	}
+	if e := res.Extended; e == nil || !e.Found() || !e.Active || !e.IPv4 || e.IPv6 {
+		t.Errorf("Invalid interface state %v", res.Extended)
+	}
+
+	// Router answers, but has no such interface
+	res, ok = p.Match(reply(ipv4.ICMPTypeExtendedEchoReply, pinger.ExtendedNoInterface, &icmp.ExtendedEchoReply{Seq: int(seq)}))
+	if !ok || res.Kind != ProbeReply || res.Extended == nil || res.Extended.Found() {
+		t.Errorf("Missing interface reply not matched: %+v", res)
+	}
+
+	// Echo reply does not answer extended echo request
+	if _, ok = p.Match(reply(ipv4.ICMPTypeEchoReply, 0, &icmp.Echo{Seq: int(seq), Data: make([]byte, 32)})); ok {
+		t.Error("Echo reply matched extended echo request")
+	}
+}
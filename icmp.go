@@ -0,0 +1,176 @@
+package multiping
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// icmpProber sends ICMP echo, timestamp or extended echo requests with session sockets, which are shared
+// by all rounds. Session routes replies and ICMP errors to prober by sequence.
+type icmpProber struct {
+	mp      *MultiPing
+	cfg     *RoundConfig
+	packets chan *pinger.Packet
+	parsed  parsedStats // replies parsed by session
+	done    chan struct{}
+
+	// Extended echo replies have no payload, so send times are kept here
+	mu   sync.Mutex
+	sent map[probeKey]time.Time
+}
+
+func (mp *MultiPing) newICMPProber(cfg *RoundConfig) *icmpProber {
+	return &icmpProber{
+		mp:      mp,
+		cfg:     cfg,
+		packets: make(chan *pinger.Packet),
+		done:    make(chan struct{}),
+		sent:    make(map[probeKey]time.Time),
+	}
+}
+
+func (p *icmpProber) Prepare(addr netip.Addr, seq uint16, opts ProbeOptions) (*pinger.Packet, error) {
+	var pkt *pinger.Packet
+	var err error
+	switch {
+	case p.cfg.Timestamp:
+		pkt, err = p.mp.pinger.PrepareTimestamp(addr, seq)
+	case p.cfg.Interface != nil:
+		pkt, err = p.mp.pinger.PrepareExtendedEcho(addr, seq, *p.cfg.Interface)
+	default:
+		pkt, err = p.mp.pinger.PrepareICMPSize(addr, seq, p.mp.payloadSize(opts.Size))
+	}
+	if err != nil {
+		return nil, err
+	}
+	pkt.TTL, pkt.TOS, pkt.DontFragment = opts.TTL, opts.TOS, opts.DontFragment
+	return pkt, nil
+}
+
+func (p *icmpProber) Send(pkt *pinger.Packet) error {
+	if p.cfg.Interface != nil {
+		p.mu.Lock()
+		p.sent[probeKey{addr: pkt.Addr, seq: pkt.Seq}] = time.Now()
+		p.mu.Unlock()
+	}
+	return p.mp.pinger.SendPacket(pkt)
+}
+
+// handleReply passes packet routed by session to Receive
+func (p *icmpProber) handleReply(pkt *pinger.Packet, stats pinger.IcmpStats) {
+	p.parsed.put(pkt, stats)
+	select {
+	case p.packets <- pkt:
+	case <-p.done:
+		p.parsed.take(pkt)
+	}
+}
+
+func (p *icmpProber) Receive() (*pinger.Packet, error) {
+	select {
+	case pkt := <-p.packets:
+		return pkt, nil
+	case <-p.done:
+		return nil, ErrProberClosed
+	}
+}
+
+func (p *icmpProber) Match(pkt *pinger.Packet) (ProbeResult, bool) {
+	pingStats, ok := p.parsed.take(pkt)
+	if !ok {
+		pingStats = p.mp.pinger.ParsePacket(pkt)
+	}
+	if !pingStats.Valid {
+		return ProbeResult{}, false
+	}
+
+	if icmpErr := pingStats.Error; icmpErr != nil {
+		return ProbeResult{Kind: ProbeError, Addr: icmpErr.Dest, Seq: pingStats.Seq, Err: icmpErr}, true
+	}
+
+	if pingStats.Forged {
+		return ProbeResult{Kind: ProbeForged, Addr: pkt.Addr, Seq: pingStats.Seq}, true
+	}
+
+	// Reply must be of the same kind as round requests
+	if (pingStats.Timestamp != nil) != p.cfg.Timestamp || (pingStats.Extended != nil) != (p.cfg.Interface != nil) {
+		return ProbeResult{}, false
+	}
+
+	if pingStats.Extended != nil {
+		return ProbeResult{
+			Kind:     ProbeReply,
+			Addr:     pkt.Addr,
+			Seq:      pingStats.Seq,
+			RTT:      p.rtt(pkt.Addr, pingStats.Seq),
+			TTL:      pkt.TTL,
+			TOS:      pkt.TOS,
+			Extended: pingStats.Extended,
+		}, true
+	}
+
+	kind := ProbeReply
+	if !p.cfg.Timestamp {
+		kind = p.mp.checkPayload(pingStats, p.cfg.probe(pkt.Addr).Size)
+	}
+	res := ProbeResult{
+		Kind: kind,
+		Addr: pkt.Addr,
+		Seq:  pingStats.Seq,
+		RTT:  pingStats.RTT,
+		TTL:  pkt.TTL,
+		TOS:  pkt.TOS,
+	}
+	if kind == ProbeReply {
+		res.Timestamp = pingStats.Timestamp
+	}
+	return res, true
+}
+
+// rtt returns time since request seq was sent to addr
+func (p *icmpProber) rtt(addr netip.Addr, seq uint16) time.Duration {
+	key := probeKey{addr: addr, seq: seq}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sent, ok := p.sent[key]
+	if !ok {
+		return 0
+	}
+	delete(p.sent, key)
+	return time.Since(sent)
+}
+
+func (p *icmpProber) Close() {
+	close(p.done)
+}
+
+// parsedStats keeps stats of packets, which session has already parsed and verified,
+// so that Match does not do it again
+type parsedStats struct {
+	mu    sync.Mutex
+	stats map[*pinger.Packet]pinger.IcmpStats
+}
+
+func (ps *parsedStats) put(pkt *pinger.Packet, stats pinger.IcmpStats) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.stats == nil {
+		ps.stats = make(map[*pinger.Packet]pinger.IcmpStats)
+	}
+	ps.stats[pkt] = stats
+}
+
+// take returns and forgets stats of pkt. Packets, which were not routed by session, are not found.
+func (ps *parsedStats) take(pkt *pinger.Packet) (pinger.IcmpStats, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	stats, ok := ps.stats[pkt]
+	delete(ps.stats, pkt)
+	return stats, ok
+}
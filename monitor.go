@@ -0,0 +1,349 @@
+package multiping
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// idle scheduler wakeup period, when there are no targets to ping
+const monitorIdle = time.Minute
+
+// Monitor pings targets continuously. Each target is pinged with its own interval.
+// Unlike MultiPing.Ping, sockets are opened once and stay open while Run is active.
+// Sockets are shared with MultiPing rounds, running at the same time.
+// Targets can be added and removed while monitor is running.
+type Monitor struct {
+	// Timeout specifies how long to wait for echo reply.
+	// Later replies are ignored and request is counted as lost.
+	Timeout time.Duration
+
+	mp *MultiPing
+
+	// Locks targets and their statistics
+	mu      sync.Mutex
+	targets map[netip.Addr]*monitorTarget
+	queue   monitorQueue
+	seqs    []monitorSeq // registered sequences in send order
+
+	// wakes up scheduler when targets change
+	wakeup chan struct{}
+}
+
+// monitorSeq is registered sequence, which replies are routed to monitor
+type monitorSeq struct {
+	seq     uint16
+	expires time.Time
+}
+
+type monitorTarget struct {
+	addr     netip.Addr
+	interval time.Duration
+	next     time.Time // time of next echo request
+	index    int       // index in monitorQueue
+	stats    pingdata.PingStats
+}
+
+// NewMonitor creates monitor, which pings through mp sockets.
+func NewMonitor(mp *MultiPing) *Monitor {
+	return &Monitor{
+		Timeout: mp.Timeout,
+		mp:      mp,
+		targets: make(map[netip.Addr]*monitorTarget),
+		wakeup:  make(chan struct{}, 1),
+	}
+}
+
+// Add adds hosts to be pinged every interval.
+// If host is already monitored, only its interval is updated.
+// Interval must be positive.
+func (m *Monitor) Add(interval time.Duration, hosts ...netip.Addr) error {
+	if interval <= 0 {
+		return fmt.Errorf("%w: interval %v must be positive", ErrInvalidOption, interval)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, ip := range hosts {
+		if t, ok := m.targets[ip]; ok {
+			t.interval = interval
+			continue
+		}
+
+		t := &monitorTarget{
+			addr:     ip,
+			interval: interval,
+			next:     now,
+		}
+		m.targets[ip] = t
+		heap.Push(&m.queue, t)
+	}
+
+	m.notify()
+	return nil
+}
+
+// Del removes hosts from monitoring
+func (m *Monitor) Del(hosts ...netip.Addr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, ip := range hosts {
+		if t, ok := m.targets[ip]; ok {
+			heap.Remove(&m.queue, t.index)
+			delete(m.targets, ip)
+		}
+	}
+
+	m.notify()
+}
+
+// Stats returns a copy of host statistics
+func (m *Monitor) Stats(ip netip.Addr) (pingdata.PingStats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, ok := m.targets[ip]; ok {
+		return t.stats, true
+	}
+	return pingdata.PingStats{}, false
+}
+
+// Snapshot returns copy of all monitored hosts statistics
+func (m *Monitor) Snapshot() *pingdata.PingData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := pingdata.NewPingData()
+	for ip, t := range m.targets {
+		data.Add(ip)
+		stats, _ := data.Get(ip)
+		*stats = t.stats
+	}
+	return data
+}
+
+// Reset statistics of all monitored hosts
+func (m *Monitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.targets {
+		t.stats.Reset()
+	}
+}
+
+// Run opens connections and pings targets until ctx is cancelled.
+// It is blocking function and always returns non nil error.
+func (m *Monitor) Run(ctx context.Context) error {
+	sess, err := m.mp.acquire()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSocketSetup, err)
+	}
+	defer m.mp.release(sess)
+
+	m.schedule(ctx, sess)
+
+	m.mu.Lock()
+	for _, s := range m.seqs {
+		m.mp.unregister(s.seq, 1)
+	}
+	m.seqs = nil
+	m.mu.Unlock()
+
+	return ctx.Err()
+}
+
+// notify wakes up scheduler. Must be called with m.mu locked
+func (m *Monitor) notify() {
+	select {
+	case m.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Monitor) schedule(ctx context.Context, sess *session) {
+	for {
+		timer := time.NewTimer(m.sendDue(ctx, sess, time.Now()))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-m.wakeup:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// sendDue pings all targets, which time has come, and returns duration till next ping.
+// All targets pinged at once share the same sequence number. Requests are paced like rounds,
+// see MultiPing.Pacing.
+func (m *Monitor) sendDue(ctx context.Context, sess *session, now time.Time) time.Duration {
+	var batch []netip.Addr
+	var seq uint16
+
+	m.mu.Lock()
+	m.expireSeqs(now)
+
+	if len(m.queue) > 0 && !m.queue[0].next.After(now) {
+		seq = m.mp.register(m, 1)
+		m.seqs = append(m.seqs, monitorSeq{seq: seq, expires: now.Add(m.Timeout)})
+
+		for len(m.queue) > 0 && !m.queue[0].next.After(now) {
+			t := m.queue[0]
+
+			if sess.supported(t.addr) {
+				t.stats.Send(seq)
+				batch = append(batch, t.addr)
+			}
+
+			// Target must move forward, otherwise this loop never ends
+			step := t.interval
+			if step <= 0 {
+				step = monitorIdle
+			}
+			// Do not try to catch up missed pings, if sending is late
+			t.next = t.next.Add(step)
+			if t.next.Before(now) {
+				t.next = now.Add(step)
+			}
+			heap.Fix(&m.queue, 0)
+		}
+	}
+
+	wait := monitorIdle
+	if len(m.queue) > 0 {
+		wait = m.queue[0].next.Sub(now)
+	}
+	m.mu.Unlock()
+
+	// Request is prepared after pacing delay, so that delay is not counted in rtt
+	for i, addr := range batch {
+		if !m.mp.wait(ctx, nil, addr) {
+			m.cancelSends(seq, batch[i:]...)
+			break
+		}
+		pkt, err := m.mp.pinger.PrepareICMP(addr, seq)
+		if err == nil {
+			err = m.mp.pinger.SendPacket(pkt)
+		}
+		if err != nil {
+			m.cancelSends(seq, addr)
+		}
+	}
+
+	// Pacing may take a while, replies are awaited for Timeout after the last request
+	if len(batch) > 0 {
+		m.mu.Lock()
+		if n := len(m.seqs); n > 0 && m.seqs[n-1].seq == seq {
+			m.seqs[n-1].expires = time.Now().Add(m.Timeout)
+		}
+		m.mu.Unlock()
+	}
+
+	return wait
+}
+
+// cancelSends reverts requests with sequence seq, which were not sent to hosts
+func (m *Monitor) cancelSends(seq uint16, hosts ...netip.Addr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, ip := range hosts {
+		if t, ok := m.targets[ip]; ok {
+			t.stats.CancelSend(seq)
+		}
+	}
+}
+
+// expireSeqs stops routing replies of timed out sequences. Must be called with m.mu locked
+func (m *Monitor) expireSeqs(now time.Time) {
+	for len(m.seqs) > 0 && m.seqs[0].expires.Before(now) {
+		m.mp.unregister(m.seqs[0].seq, 1)
+		m.seqs = m.seqs[1:]
+	}
+}
+
+func (m *Monitor) handleReply(pkt *pinger.Packet, pingStats pinger.IcmpStats) {
+	// Monitor sends echo requests only
+	if pingStats.RTT > m.Timeout || pingStats.Timestamp != nil {
+		return
+	}
+
+	var change *DistanceChange
+	m.mu.Lock()
+	if pingStats.Forged {
+		if t, ok := m.targets[pkt.Addr]; ok {
+			t.stats.RecvForged()
+		}
+	} else if icmpErr := pingStats.Error; icmpErr != nil {
+		if t, ok := m.targets[icmpErr.Dest]; ok {
+			t.stats.RecvError(pingStats.Seq, icmpErr.Type, icmpErr.Code, icmpErr.Router)
+		}
+	} else if t, ok := m.targets[pkt.Addr]; ok {
+		change = m.recv(t, pkt, pingStats)
+	}
+	m.mu.Unlock()
+
+	if fn := m.mp.OnDistanceChange; fn != nil && change != nil {
+		fn(*change)
+	}
+}
+
+// recv registers echo reply of target. Returns hop distance change, if any.
+// Must be called with m.mu locked
+func (m *Monitor) recv(t *monitorTarget, pkt *pinger.Packet, pingStats pinger.IcmpStats) *DistanceChange {
+	var change *DistanceChange
+	switch m.mp.checkPayload(pingStats, 0) {
+	case ProbeTruncated:
+		t.stats.RecvTruncated(pingStats.Seq)
+	case ProbeCorrupted:
+		t.stats.RecvCorrupted(pingStats.Seq)
+	default:
+		if !t.stats.Recv(pingStats.Seq, pingStats.RTT) {
+			break
+		}
+		if old := t.stats.Distance(); t.stats.SetTTL(pkt.TTL) {
+			change = &DistanceChange{Addr: pkt.Addr, Old: old, New: t.stats.Distance(), TTL: pkt.TTL}
+		}
+		t.stats.SetTOS(m.mp.sentTOS(pkt.Addr, 0), pkt.TOS)
+	}
+	return change
+}
+
+// monitorQueue is a heap of targets ordered by next ping time
+type monitorQueue []*monitorTarget
+
+func (q monitorQueue) Len() int           { return len(q) }
+func (q monitorQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+
+func (q monitorQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *monitorQueue) Push(x any) {
+	t := x.(*monitorTarget)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+
+func (q *monitorQueue) Pop() any {
+	old := *q
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return t
+}
@@ -0,0 +1,100 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+
+	fast := netip.MustParseAddr("127.0.0.1")
+	slow := netip.MustParseAddr("127.0.0.2")
+	late := netip.MustParseAddr("127.0.0.3")
+
+	m := NewMonitor(mp)
+	if err = m.Add(0, fast); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected invalid option error for zero interval, got %v", err)
+	}
+	m.Add(50*time.Millisecond, fast)
+	m.Add(time.Hour, slow)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.Run(ctx)
+	}()
+
+	// Targets can be changed while monitor is running
+	time.Sleep(100 * time.Millisecond)
+	m.Add(50*time.Millisecond, late)
+	time.Sleep(100 * time.Millisecond)
+	m.Del(slow)
+
+	wg.Wait()
+
+	stats, ok := m.Stats(fast)
+	if !ok {
+		t.Fatalf("Monitored host missing")
+	}
+	if stats.Sent() < 5 || stats.Received() < stats.Sent()-1 {
+		t.Errorf("Unexpected fast host stats: %s", stats.String())
+	}
+
+	stats, ok = m.Stats(late)
+	if !ok {
+		t.Fatalf("Host added at runtime missing")
+	}
+	if stats.Sent() < 3 || stats.Sent() >= 10 {
+		t.Errorf("Unexpected late host stats: %s", stats.String())
+	}
+
+	if _, ok = m.Stats(slow); ok {
+		t.Errorf("Removed host is still monitored")
+	}
+	if m.Snapshot().Count() != 2 {
+		t.Errorf("Invalid snapshot count")
+	}
+}
+
+func TestMonitorPacedExpiry(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.Pacing.Rate = 50
+
+	sess, err := mp.acquire()
+	if err != nil {
+		t.Fatalf("Session setup failed %s", err)
+	}
+	defer mp.release(sess)
+
+	m := NewMonitor(mp)
+	m.Timeout = 30 * time.Millisecond
+	for i := 1; i <= 5; i++ {
+		m.Add(time.Hour, netip.MustParseAddr(fmt.Sprintf("127.0.0.%d", i)))
+	}
+
+	// Pacing of 5 requests takes at least 80ms, longer than timeout
+	start := time.Now()
+	m.sendDue(context.Background(), sess, start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.seqs) != 1 || m.seqs[0].expires.Before(start.Add(80*time.Millisecond+m.Timeout)) {
+		t.Errorf("Sequence expires before replies to the last request: %+v", m.seqs)
+	}
+}
@@ -19,138 +19,170 @@ package multiping
  **/
 
 import (
-	"context"
+	"fmt"
 	"math/rand"
+	"net/netip"
 	"sync"
 	"time"
 
-	"github.com/drgkaleda/go-multiping/pingdata"
 	"github.com/drgkaleda/go-multiping/pinger"
-	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
-	"golang.org/x/net/ipv6"
-)
-
-var (
-	ipv4Proto = map[string]string{"icmp": "ip4:icmp", "udp": "udp4"}
-	ipv6Proto = map[string]string{"icmp": "ip6:ipv6-icmp", "udp": "udp6"}
 )
 
 type MultiPing struct {
-	// Locks MultiPing to protect internal members
-	sync.RWMutex
+	// Timeout specifies how long to wait for replies after the last echo request
+	// was sent, regardless of how many packets have been received. Default is 1s.
+	Timeout time.Duration
 
-	// Sync internal goroutines
-	wg sync.WaitGroup
+	// Count of echo requests sent to every host in a single round. Default is 1.
+	Count int
 
-	// Timeout specifies a timeout before ping exits, regardless of how many
-	// packets have been received. Default is 1s.
-	Timeout time.Duration
+	// Interval between echo requests to the same host, when Count > 1. Default is 1s.
+	Interval time.Duration
+
+	// FinishEarly ends round as soon as every echo request is either answered
+	// or its timeout has passed, instead of always waiting Timeout after the last request.
+	FinishEarly bool
+
+	// Pacing limits how fast echo requests are sent. Default is unlimited.
+	Pacing Pacing
 
 	// Tracker: Used to uniquely identify packet when non-priviledged
 	Tracker int64
 
-	ctx    context.Context    // context for timeouting
-	cancel context.CancelFunc // Do I need it ?
+	// OnDistanceChange is called, when hop distance to host inferred from reply TTL changes.
+	// It is a cheap signal of a reroute. Called after round ends, or from receiver goroutine by Monitor.
+	OnDistanceChange func(DistanceChange)
 
-	pinger   *pinger.Pinger
-	pingData *pingdata.PingData
+	pinger  *pinger.Pinger
+	limiter limiter
 
 	id       uint16
-	sequence uint16 // ICMP seq number. Incremented on every ping
 	network  string // one of "ip", "ip4", or "ip6"
 	protocol string // protocol is "icmp" or "udp".
-	conn4    *icmp.PacketConn
-	conn6    *icmp.PacketConn
-	rxChan   chan *pinger.Packet
-	txChan   chan *pinger.Packet
+
+	detection Detection // how socket mode was chosen
+
+	// Socket options
+	listen4 pinger.ListenConfig
+	listen6 pinger.ListenConfig
+
+	// Locks session. Held while session is opened or closed
+	sessMu sync.Mutex
+	sess   *session
+
+	// Locks reply handlers and sequence counter
+	mu       sync.Mutex
+	handlers map[uint16]replyHandler // in-flight reply handlers by echo request sequence
+	sequence uint16                  // next free ICMP seq number
+	ports    map[uint16]replyHandler // handlers of ICMP errors about UDP probes by probe source port
 }
 
+// New creates MultiPing with default settings.
+// It is the same as NewWithOptions(WithPrivileged(privileged)).
 func New(privileged bool) (*MultiPing, error) {
-	protocol := "udp"
-	if privileged {
-		protocol = "icmp"
-	}
+	return NewWithOptions(WithPrivileged(privileged))
+}
 
+// NewWithOptions creates MultiPing configured with opts.
+// Options are validated and applied in order, sockets are tested afterwards.
+// Unless WithPrivileged is given, socket mode is chosen with DetectMode.
+func NewWithOptions(opts ...Option) (*MultiPing, error) {
 	rand.Seed(time.Now().UnixNano())
 	mp := &MultiPing{
 		Timeout:  time.Second,
+		Count:    1,
+		Interval: time.Second,
 		id:       uint16(rand.Intn(0xffff)),
 		network:  "ip",
-		protocol: protocol,
+		protocol: "udp",
 		Tracker:  rand.Int63(),
+		handlers: make(map[uint16]replyHandler),
+		ports:    make(map[uint16]replyHandler),
+		limiter: limiter{
+			global:   &pacer{},
+			prefixes: make(map[netip.Prefix]*pacer),
+		},
 	}
 
 	mp.pinger = pinger.NewPinger(mp.network, mp.protocol, mp.id)
+	for _, opt := range opts {
+		if err := opt(mp); err != nil {
+			return nil, err
+		}
+	}
+
+	if !mp.detection.Explicit {
+		mp.detection = DetectMode()
+		if mp.detection.Mode == ModeNone {
+			return nil, fmt.Errorf("%w: %v", ErrSocketSetup, mp.detection)
+		}
+	}
+
+	privileged := mp.detection.Mode == ModePrivileged
+	mp.protocol = "udp"
+	if privileged {
+		mp.protocol = "icmp"
+	}
 	mp.pinger.SetPrivileged(privileged)
 	mp.pinger.Tracker = mp.Tracker
+	mp.listen4.Privileged = privileged
+	mp.listen6.Privileged = privileged
 
 	// try initialise connections to test that everything's working
-	err := mp.restart()
+	c4, c6, err := mp.openConns()
 	if err != nil {
-		mp.closeConnection()
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrSocketSetup, err)
 	}
+	closeConns(c4, c6)
 
-	// Sequence counter. It will be incremented in mp.restart on every ping
+	// Sequence counter. It will be advanced on every ping
 	// Start with quite big initial value, so overwrap will occure fast (easier debugin)
 	mp.sequence = 0xfff0
 
 	return mp, nil
 }
 
-func (mp *MultiPing) restart() (err error) {
+// Mode returns socket mode used for pinging
+func (mp *MultiPing) Mode() Mode {
+	return mp.detection.Mode
+}
+
+// Detection returns how socket mode was chosen and why other modes were rejected
+func (mp *MultiPing) Detection() Detection {
+	return mp.detection
+}
+
+// openConns opens IPv4 and IPv6 connections of configured network.
+// With "ip" network IPv6 may be disabled on OS, so its failure is not an error and c6 is nil then.
+func (mp *MultiPing) openConns() (c4, c6 *pinger.Conn, err error) {
 	// ipv4
-	mp.conn4, err = icmp.ListenPacket(ipv4Proto[mp.protocol], "")
-	if err != nil {
-		return err
-	}
-	err = mp.conn4.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
-	if err != nil {
-		return err
+	if mp.network != "ip6" {
+		c4, err = mp.listen4.Listen(pinger.ProtocolIpv4)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// ipv6 (note IPv6 may be disabled on OS and may fail)
-	mp.conn6, err = icmp.ListenPacket(ipv6Proto[mp.protocol], "")
-	if err == nil {
-		mp.conn6.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
-	}
-
-	mp.pinger.SetConns(mp.conn4, mp.conn6)
-	mp.sequence++
-	// I use zero sequence number in statistics struct
-	// to detect duplicates, thus don't use it as valid sequence number
-	if mp.sequence == 0 {
-		mp.sequence++
+	if mp.network != "ip4" {
+		c6, err = mp.listen6.Listen(pinger.ProtocolIpv6)
+		if err != nil {
+			if mp.network == "ip6" {
+				return nil, nil, err
+			}
+			c6 = nil
+		}
 	}
 
-	mp.rxChan = make(chan *pinger.Packet)
-	mp.txChan = make(chan *pinger.Packet)
-
-	return nil
+	return c4, c6, nil
 }
 
-// closes active connections
-func (mp *MultiPing) closeConnection() {
-	if mp.conn4 != nil {
-		mp.conn4.Close()
+// closeConns closes connections, any of them may be nil
+func closeConns(c4, c6 *pinger.Conn) {
+	if c4 != nil {
+		c4.Close()
 	}
-	if mp.conn6 != nil {
-		mp.conn6.Close()
+	if c6 != nil {
+		c6.Close()
 	}
 }
-
-// cleanup cannot be done in close, because some goroutines may be using struct members
-func (mp *MultiPing) cleanup() {
-	// Close rx channel.
-	// Tx channel is closed in batchPrepareIcmp()
-	close(mp.rxChan)
-
-	// invalidate connections
-	mp.conn4 = nil
-	mp.conn6 = nil
-	mp.pinger.SetConns(nil, nil)
-
-	// Invalidate pingData pointer (prevent from possible data corruption in future)
-	mp.pingData = nil
-}
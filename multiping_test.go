@@ -1,11 +1,16 @@
 package multiping
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/netip"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
 )
 
 func TestMultiping(t *testing.T) {
@@ -45,3 +50,353 @@ func TestMultiping(t *testing.T) {
 		t.Errorf("Non existing host invalid stats")
 	}
 }
+
+func TestPingContext(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+
+	data := pingdata.NewPingData()
+	data.Add(netip.MustParseAddr("127.0.0.1"))
+	summary, err := mp.PingContext(context.Background(), data)
+	if err != nil {
+		t.Errorf("Localhost ping failed: %s", err)
+	}
+	if summary.Sent != 1 || summary.Received != 1 || summary.SendFailed != 0 {
+		t.Errorf("Unexpected round summary %+v", summary)
+	}
+
+	// Invalid address has no address family
+	data.Add(netip.Addr{})
+	summary, err = mp.PingContext(context.Background(), data)
+	if !errors.Is(err, ErrUnsupportedFamily) {
+		t.Errorf("Expected unsupported family error, got %v", err)
+	}
+	if summary.SendFailed != 1 || summary.Received != 1 {
+		t.Errorf("Unexpected round summary %+v", summary)
+	}
+
+	// Cancelled context must stop round immediately
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	_, err = mp.PingContext(ctx, data)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context cancel error, got %v", err)
+	}
+	if time.Since(start) >= mp.Timeout {
+		t.Errorf("Cancelled ping was not interrupted")
+	}
+}
+
+func TestPingCount(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.Count = 3
+	mp.Interval = 50 * time.Millisecond
+	mp.Timeout = 200 * time.Millisecond
+
+	data := pingdata.NewPingData()
+	data.Add(netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("127.0.0.2"))
+
+	// Run twice to check that sequences of different rounds do not mix
+	for round := 1; round <= 2; round++ {
+		start := time.Now()
+		summary, err := mp.PingContext(context.Background(), data)
+		if err != nil {
+			t.Errorf("Ping failed: %s", err)
+		}
+		elapsed := time.Since(start)
+		if elapsed < 2*mp.Interval+mp.Timeout {
+			t.Errorf("Round finished too early: %s", elapsed)
+		}
+		if summary.Sent != 6 || summary.Received != 6 {
+			t.Errorf("Unexpected round summary %+v", summary)
+		}
+
+		data.Iterate(func(ip netip.Addr, val *pingdata.PingStats) {
+			if val.Sent() != uint(3*round) || val.Received() != uint(3*round) || val.Duplicate() != 0 {
+				t.Errorf("Unexpected %s stats: %s", ip, val.String())
+			}
+			if val.MinRtt() > val.MaxRtt() || val.MinRtt() <= 0 {
+				t.Errorf("Invalid %s rtt min=%s max=%s", ip, val.MinRtt(), val.MaxRtt())
+			}
+		})
+	}
+}
+
+func TestFinishEarly(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.FinishEarly = true
+	mp.Count = 2
+	mp.Interval = 20 * time.Millisecond
+
+	data := pingdata.NewPingData()
+	for i := 1; i <= 10; i++ {
+		data.Add(netip.MustParseAddr(fmt.Sprintf("127.0.0.%d", i)))
+	}
+
+	summary, err := mp.PingContext(context.Background(), data)
+	if err != nil {
+		t.Errorf("Ping failed: %s", err)
+	}
+	if !summary.Early || summary.Duration >= mp.Timeout {
+		t.Errorf("Round did not finish early: %+v", summary)
+	}
+	if summary.Received != 20 {
+		t.Errorf("Unexpected round summary %+v", summary)
+	}
+
+	// Without early finish round must take full timeout
+	mp.FinishEarly = false
+	mp.Timeout = 100 * time.Millisecond
+	summary, _ = mp.PingContext(context.Background(), data)
+	if summary.Early || summary.Duration < mp.Interval+mp.Timeout {
+		t.Errorf("Round finished early: %+v", summary)
+	}
+}
+
+func TestConcurrentPing(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+
+	timeouts := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 600 * time.Millisecond}
+	elapsed := make([]time.Duration, len(timeouts))
+	summaries := make([]RoundSummary, len(timeouts))
+
+	var wg sync.WaitGroup
+	for i, timeout := range timeouts {
+		wg.Add(1)
+		go func(i int, timeout time.Duration) {
+			defer wg.Done()
+
+			data := pingdata.NewPingData()
+			for j := 1; j <= 50; j++ {
+				data.Add(netip.MustParseAddr(fmt.Sprintf("127.0.%d.%d", i, j)))
+			}
+
+			cfg := mp.Config()
+			cfg.Timeout = timeout
+			start := time.Now()
+			summaries[i], _ = mp.PingConfig(context.Background(), data, cfg)
+			elapsed[i] = time.Since(start)
+		}(i, timeout)
+	}
+	wg.Wait()
+
+	for i, timeout := range timeouts {
+		if summaries[i].Sent != 50 || summaries[i].Received != 50 || summaries[i].Unmatched != 0 {
+			t.Errorf("Round %d unexpected summary %+v", i, summaries[i])
+		}
+		// Rounds must run in parallel, not one after another
+		if elapsed[i] < timeout || elapsed[i] > timeout+200*time.Millisecond {
+			t.Errorf("Round %d took %s, expected %s", i, elapsed[i], timeout)
+		}
+	}
+}
+
+func TestDistanceChange(t *testing.T) {
+	var changes []DistanceChange
+	mp, err := NewWithOptions(WithPrivileged(false), WithDistanceChange(func(c DistanceChange) {
+		changes = append(changes, c)
+	}))
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+
+	local := netip.MustParseAddr("127.0.0.1")
+	data := pingdata.NewPingData()
+	data.Add(local)
+
+	mp.Ping(data)
+	stats, _ := data.Get(local)
+	if stats.TTL() == 0 {
+		t.Skip("Reply TTL is not available")
+	}
+	if len(changes) != 0 {
+		t.Fatalf("Unexpected distance change %+v", changes)
+	}
+
+	// Pretend host was farther away in previous round
+	data.Reset()
+	stats.SetTTL(stats.TTL() - 3)
+	mp.Ping(data)
+	if len(changes) != 1 || changes[0].Addr != local || changes[0].Old != changes[0].New+3 {
+		t.Errorf("Distance change was not reported %+v", changes)
+	}
+}
+
+func TestProbeOptions(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+
+	local := netip.MustParseAddr("127.0.0.1")
+	data := pingdata.NewPingData()
+	data.Add(local, netip.MustParseAddr("127.0.0.2"))
+
+	cfg := mp.Config()
+	cfg.Probe = ProbeOptions{TTL: 5, TOS: 46 << 2}
+	cfg.Targets = map[netip.Addr]ProbeOptions{local: {TTL: 1}}
+	if _, err = mp.PingConfig(context.Background(), data, cfg); err != nil {
+		t.Fatalf("Ping with probe options failed: %s", err)
+	}
+	data.Iterate(func(addr netip.Addr, stats *pingdata.PingStats) {
+		if stats.Loss() != 0 {
+			t.Errorf("%s ping failed: %f", addr, stats.Loss())
+		}
+	})
+
+	cfg.Targets[local] = ProbeOptions{TOS: 256}
+	if _, err = mp.PingConfig(context.Background(), data, cfg); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Invalid TOS accepted: %v", err)
+	}
+}
+
+func TestReplyDSCP(t *testing.T) {
+	for _, privileged := range []bool{false, true} {
+		mp, err := New(privileged)
+		if err != nil {
+			t.Logf("Privileged %v is not available: %s", privileged, err)
+			continue
+		}
+
+		data := pingdata.NewPingData()
+		data.Add(netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("::1"))
+
+		cfg := mp.Config()
+		cfg.Probe.TOS = 46 << 2
+		summary, err := mp.PingConfig(context.Background(), data, cfg)
+		if err != nil && !errors.Is(err, ErrUnsupportedFamily) {
+			t.Fatalf("Ping failed: %s", err)
+		}
+		if summary.Remarked != 0 {
+			t.Errorf("Localhost replies must not be remarked")
+		}
+
+		// Localhost reflects TOS of request
+		data.Iterate(func(addr netip.Addr, stats *pingdata.PingStats) {
+			if stats.Received() > 0 && stats.DSCP() != 46 {
+				t.Errorf("%s privileged %v: invalid reply DSCP %d", addr, privileged, stats.DSCP())
+			}
+		})
+	}
+}
+
+func TestPayloadIntegrity(t *testing.T) {
+	mp, err := NewWithOptions(
+		WithPattern(pinger.Pattern{Kind: pinger.PatternRandom, Seed: 1}),
+		WithPayloadSize(1400),
+	)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+
+	local := netip.MustParseAddr("127.0.0.1")
+	data := pingdata.NewPingData()
+	data.Add(local, netip.MustParseAddr("127.0.0.2"))
+
+	// Large replies must not be truncated by receive buffer
+	cfg := mp.Config()
+	cfg.Targets = map[netip.Addr]ProbeOptions{local: {Size: 9000}}
+	summary, err := mp.PingConfig(context.Background(), data, cfg)
+	if err != nil {
+		t.Fatalf("Ping failed: %s", err)
+	}
+	if summary.Corrupted != 0 || summary.Truncated != 0 || summary.Received != 2 {
+		t.Errorf("Invalid summary %+v", summary)
+	}
+
+	checks := []struct {
+		stats pinger.IcmpStats
+		kind  ProbeKind
+	}{
+		{pinger.IcmpStats{Size: 1400}, ProbeReply},
+		{pinger.IcmpStats{Size: 1000}, ProbeTruncated},
+		{pinger.IcmpStats{Size: 1500}, ProbeCorrupted},
+		{pinger.IcmpStats{Size: 1400, Corrupted: true}, ProbeCorrupted},
+	}
+	for _, c := range checks {
+		if kind := mp.checkPayload(c.stats, 0); kind != c.kind {
+			t.Errorf("Payload %+v: expected %s, got %s", c.stats, c.kind, kind)
+		}
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	for _, privileged := range []bool{false, true} {
+		mp, err := NewWithOptions(WithPrivileged(privileged), WithAuthentication(nil), WithTimeout(500*time.Millisecond))
+		if err != nil {
+			t.Logf("Privileged %v is not available: %s", privileged, err)
+			continue
+		}
+
+		local := netip.MustParseAddr("127.0.0.1")
+		data := pingdata.NewPingData()
+		data.Add(local, netip.MustParseAddr("127.0.0.2"))
+		summary, err := mp.PingConfig(context.Background(), data, mp.Config())
+		if err != nil {
+			t.Fatalf("Privileged %v: ping failed: %s", privileged, err)
+		}
+		if summary.Received != 2 || summary.Forged != 0 || summary.Corrupted != 0 {
+			t.Errorf("Privileged %v: invalid summary %+v", privileged, summary)
+		}
+
+		// Forged reply is counted, but request still waits for real one
+		const seq = 3131
+		stats, _ := data.Get(local)
+		stats.Send(seq)
+		r := newRound(context.Background(), mp, nil, data, mp.Config())
+		r.handleResult(ProbeResult{Kind: ProbeForged, Addr: local, Seq: seq})
+		r.handleResult(ProbeResult{Kind: ProbeReply, Addr: local, Seq: seq, RTT: time.Millisecond, TOS: -1})
+		r.cancel()
+		if r.summary.Forged != 1 || r.summary.Received != 1 || stats.Forged() != 1 {
+			t.Errorf("Privileged %v: forged reply summary %+v, stats forged %d", privileged, r.summary, stats.Forged())
+		}
+	}
+
+	if _, err := NewWithOptions(WithAuthentication([]byte("secret"))); err != nil {
+		t.Errorf("Authentication with secret failed: %s", err)
+	}
+}
+
+func TestPingTimestamp(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	data := pingdata.NewPingData()
+	data.Add(netip.MustParseAddr("127.0.0.1"))
+	if _, err = mp.PingTimestamp(context.Background(), data); !errors.Is(err, ErrPrivilegedOnly) {
+		t.Errorf("Expected privileged only error, got %v", err)
+	}
+
+	mp, err = New(true)
+	if err != nil {
+		t.Skipf("Privileged mode is not available: %s", err)
+	}
+	local := netip.MustParseAddr("127.0.0.1")
+	data.Add(netip.MustParseAddr("::1"))
+	summary, err := mp.PingTimestamp(context.Background(), data)
+	if !errors.Is(err, ErrUnsupportedFamily) {
+		t.Errorf("Expected IPv6 host to be skipped, got %v", err)
+	}
+	if summary.Received != 1 {
+		t.Fatalf("Invalid summary %+v", summary)
+	}
+
+	stats, _ := data.Get(local)
+	offset, _, _, ok := stats.Clock()
+	if !ok || offset < -10*time.Millisecond || offset > 10*time.Millisecond {
+		t.Errorf("Invalid localhost clock offset %s, known %v", offset, ok)
+	}
+}
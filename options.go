@@ -0,0 +1,224 @@
+package multiping
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// Option configures MultiPing created with NewWithOptions
+type Option func(mp *MultiPing) error
+
+// WithPrivileged selects raw ICMP sockets (true), which require super-user privileges,
+// or "unprivileged" datagram ICMP sockets (false) instead of detecting them.
+func WithPrivileged(privileged bool) Option {
+	return func(mp *MultiPing) error {
+		mp.detection = Detection{Mode: ModeUnprivileged, Explicit: true}
+		if privileged {
+			mp.detection.Mode = ModePrivileged
+		}
+		return nil
+	}
+}
+
+// WithTimeout sets Timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(mp *MultiPing) error {
+		if timeout <= 0 {
+			return fmt.Errorf("%w: timeout %v must be positive", ErrInvalidOption, timeout)
+		}
+		mp.Timeout = timeout
+		return nil
+	}
+}
+
+// WithCount sets Count
+func WithCount(count int) Option {
+	return func(mp *MultiPing) error {
+		if count < 1 {
+			return fmt.Errorf("%w: count %d must be at least 1", ErrInvalidOption, count)
+		}
+		mp.Count = count
+		return nil
+	}
+}
+
+// WithInterval sets Interval
+func WithInterval(interval time.Duration) Option {
+	return func(mp *MultiPing) error {
+		if interval < 0 {
+			return fmt.Errorf("%w: interval %v must not be negative", ErrInvalidOption, interval)
+		}
+		mp.Interval = interval
+		return nil
+	}
+}
+
+// WithTracker sets Tracker
+func WithTracker(tracker int64) Option {
+	return func(mp *MultiPing) error {
+		mp.Tracker = tracker
+		return nil
+	}
+}
+
+// WithPayloadSize sets size of echo request payload in bytes.
+// Payload carries timestamp and tracker, so it can't be smaller than pinger.MinSize.
+func WithPayloadSize(size int) Option {
+	return func(mp *MultiPing) error {
+		if size < pinger.MinSize || size > pinger.MaxSize {
+			return fmt.Errorf("%w: payload size %d is out of range [%d, %d]",
+				ErrInvalidOption, size, pinger.MinSize, pinger.MaxSize)
+		}
+		mp.pinger.Size = size
+		return nil
+	}
+}
+
+// WithPattern sets pattern, which fills echo request payload after timestamp and tracker.
+// Echo reply payload is compared with it, mismatching replies are counted as corrupted.
+func WithPattern(pattern pinger.Pattern) Option {
+	return func(mp *MultiPing) error {
+		if !pattern.Valid() {
+			return fmt.Errorf("%w: unknown payload pattern kind %d", ErrInvalidOption, pattern.Kind)
+		}
+		mp.pinger.Pattern = pattern
+		return nil
+	}
+}
+
+// WithAuthentication adds HMAC of echo request id, sequence, timestamp, tracker and destination to payload,
+// so that tracker can't be reused to fake replies. Replies, which fail verification, are counted as forged,
+// see PingStats.Forged. Empty secret is replaced by random one, which is known to this instance only.
+// Payload grows by pinger.MACSize bytes. Echo id is authenticated in privileged mode only,
+// as datagram sockets replace it.
+func WithAuthentication(secret []byte) Option {
+	return func(mp *MultiPing) error {
+		if len(secret) == 0 {
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return fmt.Errorf("%w: secret: %v", ErrInvalidOption, err)
+			}
+		}
+		mp.pinger.Secret = append([]byte(nil), secret...)
+		return nil
+	}
+}
+
+// WithNetwork limits address families to ping: "ip4", "ip6" or "ip" (both, default).
+// Hosts of other family are not pinged and reported as ErrUnsupportedFamily.
+func WithNetwork(network string) Option {
+	return func(mp *MultiPing) error {
+		switch network {
+		case "ip", "ip4", "ip6":
+			mp.network = network
+			return nil
+		}
+		return fmt.Errorf("%w: network %q must be one of ip, ip4 or ip6", ErrInvalidOption, network)
+	}
+}
+
+// WithSource binds sockets of addr family to addr.
+// Use it once for IPv4 and once for IPv6 address to bind both families.
+func WithSource(addr netip.Addr) Option {
+	return func(mp *MultiPing) error {
+		if !addr.IsValid() {
+			return fmt.Errorf("%w: source address is not valid", ErrInvalidOption)
+		}
+		addr = addr.Unmap()
+		if addr.Is4() {
+			mp.listen4.Source = addr
+		} else {
+			mp.listen6.Source = addr
+		}
+		return nil
+	}
+}
+
+// WithTTL sets TTL (IPv6 hop limit) of echo requests
+func WithTTL(ttl int) Option {
+	return func(mp *MultiPing) error {
+		if ttl < 1 || ttl > 255 {
+			return fmt.Errorf("%w: TTL %d is out of range [1, 255]", ErrInvalidOption, ttl)
+		}
+		mp.listen4.TTL = ttl
+		mp.listen6.TTL = ttl
+		return nil
+	}
+}
+
+// WithTOS sets TOS (IPv6 traffic class) of echo requests
+func WithTOS(tos int) Option {
+	return func(mp *MultiPing) error {
+		if tos < 0 || tos > 255 {
+			return fmt.Errorf("%w: TOS %d is out of range [0, 255]", ErrInvalidOption, tos)
+		}
+		mp.listen4.TOS = tos
+		mp.listen6.TOS = tos
+		return nil
+	}
+}
+
+// WithReadBuffer sets socket receive buffer size in bytes.
+// Bigger buffer helps not to lose replies, when pinging many hosts at once.
+func WithReadBuffer(size int) Option {
+	return func(mp *MultiPing) error {
+		if size <= 0 {
+			return fmt.Errorf("%w: read buffer size %d must be positive", ErrInvalidOption, size)
+		}
+		mp.listen4.ReadBuffer = size
+		mp.listen6.ReadBuffer = size
+		return nil
+	}
+}
+
+// WithWriteBuffer sets socket send buffer size in bytes
+func WithWriteBuffer(size int) Option {
+	return func(mp *MultiPing) error {
+		if size <= 0 {
+			return fmt.Errorf("%w: write buffer size %d must be positive", ErrInvalidOption, size)
+		}
+		mp.listen4.WriteBuffer = size
+		mp.listen6.WriteBuffer = size
+		return nil
+	}
+}
+
+// WithDistanceChange sets OnDistanceChange callback
+func WithDistanceChange(fn func(DistanceChange)) Option {
+	return func(mp *MultiPing) error {
+		mp.OnDistanceChange = fn
+		return nil
+	}
+}
+
+// WithPacing sets Pacing
+func WithPacing(pacing Pacing) Option {
+	return func(mp *MultiPing) error {
+		if err := pacing.validate(); err != nil {
+			return err
+		}
+		mp.Pacing = pacing
+		return nil
+	}
+}
+
+// validate checks pacing values
+func (p Pacing) validate() error {
+	if p.Rate < 0 || p.PrefixRate < 0 {
+		return fmt.Errorf("%w: pacing rates must not be negative", ErrInvalidOption)
+	}
+	if p.PrefixLen4 < 0 || p.PrefixLen4 > 32 {
+		return fmt.Errorf("%w: IPv4 pacing prefix length %d is out of range [0, 32]", ErrInvalidOption, p.PrefixLen4)
+	}
+	if p.PrefixLen6 < 0 || p.PrefixLen6 > 128 {
+		return fmt.Errorf("%w: IPv6 pacing prefix length %d is out of range [0, 128]", ErrInvalidOption, p.PrefixLen6)
+	}
+	if p.Spread < 0 {
+		return fmt.Errorf("%w: pacing spread %v must not be negative", ErrInvalidOption, p.Spread)
+	}
+	return nil
+}
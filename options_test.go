@@ -0,0 +1,67 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+func TestOptionsInvalid(t *testing.T) {
+	invalid := map[string]Option{
+		"timeout":     WithTimeout(0),
+		"count":       WithCount(0),
+		"interval":    WithInterval(-time.Second),
+		"size small":  WithPayloadSize(8),
+		"size big":    WithPayloadSize(70000),
+		"network":     WithNetwork("udp"),
+		"source":      WithSource(netip.Addr{}),
+		"ttl":         WithTTL(256),
+		"tos":         WithTOS(-1),
+		"read buffer": WithReadBuffer(0),
+		"pacing":      WithPacing(Pacing{PrefixLen4: 33}),
+		"pattern":     WithPattern(pinger.Pattern{Kind: 10}),
+	}
+
+	for name, opt := range invalid {
+		_, err := NewWithOptions(opt)
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: expected invalid option error, got %v", name, err)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	mp, err := NewWithOptions(
+		WithTimeout(500*time.Millisecond),
+		WithPayloadSize(1000),
+		WithNetwork("ip4"),
+		WithSource(netip.MustParseAddr("127.0.0.1")),
+		WithTTL(10),
+		WithTOS(0x20),
+		WithReadBuffer(1<<20),
+		WithWriteBuffer(1<<20),
+	)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	if mp.Timeout != 500*time.Millisecond || mp.pinger.Size != 1000 {
+		t.Errorf("Options were not applied")
+	}
+
+	data := pingdata.NewPingData()
+	data.Add(netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("::1"))
+	_, err = mp.PingContext(context.Background(), data)
+	if !errors.Is(err, ErrUnsupportedFamily) {
+		t.Errorf("Expected IPv6 host to be skipped, got %v", err)
+	}
+
+	stats, _ := data.Get(netip.MustParseAddr("127.0.0.1"))
+	if stats.Loss() != 0 {
+		t.Errorf("Localhost ping failed: %f", stats.Loss())
+	}
+}
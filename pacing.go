@@ -0,0 +1,173 @@
+package multiping
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Limit of remembered prefixes. When exceeded, idle prefixes are forgotten.
+const maxPacedPrefixes = 4096
+
+// Pacing limits how fast echo requests are sent.
+// Bursts of requests may overflow local NIC buffers or trigger ICMP
+// rate limiters on the way and cause false packet loss.
+type Pacing struct {
+	// Rate limits echo requests per second of all rounds and monitors. Zero means unlimited.
+	Rate int
+
+	// PrefixRate limits echo requests per second to the same destination prefix.
+	// Zero means unlimited.
+	PrefixRate int
+
+	// Prefix lengths used with PrefixRate. Default is /24 for IPv4 and /64 for IPv6.
+	PrefixLen4 int
+	PrefixLen6 int
+
+	// Spread sends echo requests of every round evenly across this duration.
+	// When Count > 1, it applies to each batch of requests with the same sequence.
+	Spread time.Duration
+}
+
+// pacer gives out evenly spaced send slots
+type pacer struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// reserve returns the first free slot not earlier than t
+func (p *pacer) reserve(t time.Time) time.Time {
+	if p.next.After(t) {
+		t = p.next
+	}
+	p.next = t.Add(p.interval)
+	return t
+}
+
+// limiter paces echo requests of all MultiPing rounds
+type limiter struct {
+	mu       sync.Mutex
+	global   *pacer
+	prefixes map[netip.Prefix]*pacer
+}
+
+// wait blocks until echo request to addr may be sent according to pacing and round spread.
+// Returns false if ctx is done meanwhile.
+func (mp *MultiPing) wait(ctx context.Context, spread *pacer, addr netip.Addr) bool {
+	slot := mp.reserve(time.Now(), spread, addr)
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// reserve returns time, when echo request to addr may be sent
+func (mp *MultiPing) reserve(now time.Time, spread *pacer, addr netip.Addr) time.Time {
+	slot := now
+	if spread != nil {
+		slot = spread.reserve(slot)
+	}
+
+	pacing := mp.Pacing
+	if pacing.Rate <= 0 && pacing.PrefixRate <= 0 {
+		return slot
+	}
+
+	mp.limiter.mu.Lock()
+	defer mp.limiter.mu.Unlock()
+
+	if pacing.PrefixRate > 0 {
+		prefix := pacing.prefix(addr)
+		p, ok := mp.limiter.prefixes[prefix]
+		if !ok {
+			if len(mp.limiter.prefixes) >= maxPacedPrefixes {
+				mp.limiter.forget(now)
+			}
+			p = &pacer{}
+			mp.limiter.prefixes[prefix] = p
+		}
+		p.interval = time.Second / time.Duration(pacing.PrefixRate)
+		slot = p.reserve(slot)
+	}
+
+	if pacing.Rate > 0 {
+		mp.limiter.global.interval = time.Second / time.Duration(pacing.Rate)
+		slot = mp.limiter.global.reserve(slot)
+	}
+
+	return slot
+}
+
+// forget removes idle prefixes. Must be called with l.mu locked
+func (l *limiter) forget(now time.Time) {
+	for prefix, p := range l.prefixes {
+		if p.next.Before(now) {
+			delete(l.prefixes, prefix)
+		}
+	}
+}
+
+// prefix returns destination prefix of addr, which PrefixRate applies to
+func (p Pacing) prefix(addr netip.Addr) netip.Prefix {
+	bits := p.PrefixLen4
+	if bits <= 0 {
+		bits = 24
+	}
+	if addr.Is6() {
+		bits = p.PrefixLen6
+		if bits <= 0 {
+			bits = 64
+		}
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.PrefixFrom(addr, addr.BitLen())
+	}
+	return prefix
+}
+
+// newSpread returns pacer, which spreads count requests across duration
+func newSpread(duration time.Duration, count int) *pacer {
+	if duration <= 0 || count <= 1 {
+		return nil
+	}
+	return &pacer{interval: duration / time.Duration(count)}
+}
+
+// interleave orders hosts so that consecutive hosts belong to different prefixes.
+// Prefix rate limit then does not block sending to other prefixes.
+func (p Pacing) interleave(hosts []netip.Addr) []netip.Addr {
+	var order []netip.Prefix
+	groups := make(map[netip.Prefix][]netip.Addr)
+	for _, addr := range hosts {
+		prefix := p.prefix(addr)
+		if _, ok := groups[prefix]; !ok {
+			order = append(order, prefix)
+		}
+		groups[prefix] = append(groups[prefix], addr)
+	}
+
+	result := make([]netip.Addr, 0, len(hosts))
+	for len(result) < len(hosts) {
+		for _, prefix := range order {
+			if group := groups[prefix]; len(group) > 0 {
+				result = append(result, group[0])
+				groups[prefix] = group[1:]
+			}
+		}
+	}
+	return result
+}
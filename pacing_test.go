@@ -0,0 +1,125 @@
+package multiping
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+)
+
+func TestPacingReserve(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.Pacing = Pacing{Rate: 1000, PrefixRate: 10}
+
+	now := time.Now()
+	a1 := netip.MustParseAddr("10.0.0.1")
+	a2 := netip.MustParseAddr("10.0.0.2")
+	b1 := netip.MustParseAddr("10.0.1.1")
+
+	// Different prefixes are limited by global rate only
+	if slot := mp.reserve(now, nil, a1); !slot.Equal(now) {
+		t.Errorf("First slot must be immediate")
+	}
+	if slot := mp.reserve(now, nil, b1); slot.Sub(now) != time.Millisecond {
+		t.Errorf("Invalid global rate slot %s", slot.Sub(now))
+	}
+
+	// Same prefix is limited by prefix rate
+	if slot := mp.reserve(now, nil, a2); slot.Sub(now) != 100*time.Millisecond {
+		t.Errorf("Invalid prefix rate slot %s", slot.Sub(now))
+	}
+
+	// Spread is applied per round
+	spread := newSpread(time.Second, 4)
+	mp.Pacing = Pacing{}
+	for i := 0; i < 4; i++ {
+		if slot := mp.reserve(now, spread, a1); slot.Sub(now) != time.Duration(i)*250*time.Millisecond {
+			t.Errorf("Invalid spread slot %d: %s", i, slot.Sub(now))
+		}
+	}
+}
+
+func TestPacingInterleave(t *testing.T) {
+	var hosts []netip.Addr
+	for i := 0; i < 3; i++ {
+		for j := 1; j <= 3; j++ {
+			hosts = append(hosts, netip.MustParseAddr(fmt.Sprintf("10.0.%d.%d", i, j)))
+		}
+	}
+	hosts = append(hosts, netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2"))
+
+	var p Pacing
+	result := p.interleave(hosts)
+	if len(result) != len(hosts) {
+		t.Fatalf("Interleave lost hosts: %v", result)
+	}
+	for i := 1; i < len(result)-1; i++ {
+		if p.prefix(result[i]) == p.prefix(result[i-1]) {
+			t.Errorf("Hosts of the same prefix are consecutive: %v", result)
+		}
+	}
+}
+
+func TestPacingRound(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.FinishEarly = true
+	mp.Pacing.Rate = 100
+
+	data := pingdata.NewPingData()
+	for i := 1; i <= 10; i++ {
+		data.Add(netip.MustParseAddr(fmt.Sprintf("127.0.0.%d", i)))
+	}
+
+	summary, err := mp.PingContext(context.Background(), data)
+	if err != nil {
+		t.Errorf("Ping failed: %s", err)
+	}
+	if summary.Received != 10 || summary.Duration < 90*time.Millisecond {
+		t.Errorf("Unexpected paced round summary %+v", summary)
+	}
+
+	// Pacing delay must not be counted in rtt
+	data.Iterate(func(ip netip.Addr, val *pingdata.PingStats) {
+		if val.MaxRtt() > 10*time.Millisecond {
+			t.Errorf("Host %s rtt includes pacing delay: %s", ip, val.MaxRtt())
+		}
+	})
+}
+
+func TestPacingMonitor(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.Pacing.Rate = 50
+
+	m := NewMonitor(mp)
+	for i := 1; i <= 10; i++ {
+		m.Add(time.Hour, netip.MustParseAddr(fmt.Sprintf("127.0.0.%d", i)))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	m.Run(ctx)
+
+	// Stats count requests before pacing delay, so check replies
+	received := 0
+	m.Snapshot().Iterate(func(ip netip.Addr, val *pingdata.PingStats) {
+		received += int(val.Received())
+		if val.MaxRtt() > 10*time.Millisecond {
+			t.Errorf("Host %s rtt includes pacing delay: %s", ip, val.MaxRtt())
+		}
+	})
+	if received < 3 || received > 7 {
+		t.Errorf("Unexpected count of paced monitor replies %d", received)
+	}
+}
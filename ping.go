@@ -2,60 +2,234 @@ package multiping
 
 import (
 	"context"
+	"fmt"
+	"net/netip"
 	"time"
 
 	"github.com/drgkaleda/go-multiping/pingdata"
 	"github.com/drgkaleda/go-multiping/pinger"
 )
 
-// Ping is blocking function and runs for mp.Timeout time and pings all hosts in data
-func (mp *MultiPing) Ping(data *pingdata.PingData) {
-	if data.Count() == 0 {
-		return
+// RoundSummary holds packet counters of a single ping round
+type RoundSummary struct {
+	Sent       int // echo requests successfully written to socket
+	Received   int // echo replies matched to a probed host
+	SendFailed int // echo requests which could not be prepared or sent
+	Unmatched  int // replies to round requests, that did not match any outstanding request
+	Errors     int // ICMP error messages (e.g. destination unreachable) received instead of replies
+	Remarked   int // replies, which came back with DSCP different from the sent one
+	Corrupted  int // replies, which payload differs from the sent one
+	Truncated  int // replies, which payload is shorter than the sent one
+	Refused    int // replies to port probes, which refused connection
+	Forged     int // replies, which failed authentication, see WithAuthentication
+
+	Early    bool          // round finished before timeout, see MultiPing.FinishEarly
+	Duration time.Duration // how long the round took
+}
+
+// DistanceChange is reported, when hop distance to host changes between replies
+type DistanceChange struct {
+	Addr netip.Addr
+	Old  int // previous hop distance
+	New  int // current hop distance
+	TTL  int // TTL (hop limit) of reply
+}
+
+// RoundConfig holds settings of a single round.
+// By default they are taken from MultiPing fields, see MultiPing.Config.
+type RoundConfig struct {
+	Timeout     time.Duration
+	Count       int
+	Interval    time.Duration
+	FinishEarly bool
+	Spread      time.Duration // see Pacing.Spread
+
+	// Probe sets IP header values of all echo requests in round
+	Probe ProbeOptions
+	// Targets overrides Probe for some targets
+	Targets map[netip.Addr]ProbeOptions
+
+	// Timestamp sends ICMP timestamp requests instead of echo requests, see MultiPing.PingTimestamp
+	Timestamp bool
+
+	// TCP probes with TCP connections to ProbeOptions.Port instead of echo requests, see MultiPing.PingTCP
+	TCP bool
+
+	// UDP probes with UDP datagrams to ProbeOptions.Port instead of echo requests, see MultiPing.PingUDP
+	UDP bool
+
+	// DNS probes with DNS queries over UDP instead of echo requests, see MultiPing.PingDNS
+	DNS *DNSQuery
+
+	// Interface sends ICMP extended echo requests, which ask targets about state of interface,
+	// instead of echo requests, see MultiPing.PingInterfaces
+	Interface *pinger.InterfaceQuery
+
+	// Prober opens custom prober of round instead of ICMP echo prober, see Prober
+	Prober ProberFactory
+}
+
+// ProbeOptions are IP header values of echo requests. Zero values mean socket defaults,
+// see WithTTL and WithTOS. Values are set per packet with control messages.
+type ProbeOptions struct {
+	// TTL (IPv6 hop limit) in range [0, 255] (0 means default)
+	TTL int
+
+	// TOS (IPv6 traffic class) in range [0, 255] (0 means default). It holds DSCP in upper 6 bits,
+	// e.g. DSCP EF (46) is TOS 46<<2 = 184.
+	TOS int
+
+	// Size of echo request payload, see WithPayloadSize
+	Size int
+
+	// DontFragment sets DF bit (IPv6: disables local fragmentation) and ignores path MTU cache.
+	// Requests larger than path MTU are then dropped or answered with ICMP errors.
+	DontFragment bool
+
+	// Port of TCP, UDP and DNS probes (53 by default). TTL, TOS, Size and DontFragment apply to ICMP probes only.
+	Port int
+}
+
+// validate checks IP header values
+func (o ProbeOptions) validate() error {
+	if o.TTL < 0 || o.TTL > 255 {
+		return fmt.Errorf("%w: TTL %d is out of range [0, 255] (0 means default)", ErrInvalidOption, o.TTL)
+	}
+	if o.TOS < 0 || o.TOS > 255 {
+		return fmt.Errorf("%w: TOS %d is out of range [0, 255] (0 means default)", ErrInvalidOption, o.TOS)
+	}
+	if o.Port < 0 || o.Port > 65535 {
+		return fmt.Errorf("%w: port %d is out of range [0, 65535] (0 means unset)", ErrInvalidOption, o.Port)
+	}
+	if o.Size != 0 && (o.Size < pinger.MinSize || o.Size > pinger.MaxSize) {
+		return fmt.Errorf("%w: payload size %d is out of range [%d, %d]",
+			ErrInvalidOption, o.Size, pinger.MinSize, pinger.MaxSize)
+	}
+	return nil
+}
+
+// validate checks probe options of round
+func (cfg *RoundConfig) validate() error {
+	if err := cfg.Probe.validate(); err != nil {
+		return err
+	}
+	for addr, opts := range cfg.Targets {
+		if err := opts.validate(); err != nil {
+			return fmt.Errorf("%s: %w", addr, err)
+		}
 	}
+	return nil
+}
 
-	// Lock the pinger - its instance may be reused by several clients
-	mp.Lock()
-	defer mp.Unlock()
+// check validates cfg and checks that MultiPing mode supports it
+func (mp *MultiPing) check(cfg *RoundConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	kinds := 0
+	for _, set := range []bool{cfg.Timestamp, cfg.TCP, cfg.UDP, cfg.DNS != nil, cfg.Interface != nil, cfg.Prober != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("%w: only one of timestamp, TCP, UDP, DNS, extended echo and custom probes can be used",
+			ErrInvalidOption)
+	}
+	if cfg.DNS != nil {
+		if err := cfg.DNS.validate(); err != nil {
+			return err
+		}
+	}
+	if cfg.Interface != nil {
+		if err := cfg.Interface.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOption, err)
+		}
+		if cfg.Count > maxShortSeqs {
+			return fmt.Errorf("%w: count %d of extended echo requests is above %d", ErrInvalidOption, cfg.Count, maxShortSeqs)
+		}
+	}
+	if cfg.Timestamp && mp.Mode() != ModePrivileged {
+		return fmt.Errorf("%w: timestamp requests", ErrPrivilegedOnly)
+	}
+	return nil
+}
 
-	err := mp.restart()
-	if err != nil {
-		return
+// probe returns IP header values of echo requests to addr
+func (cfg *RoundConfig) probe(addr netip.Addr) ProbeOptions {
+	if opts, ok := cfg.Targets[addr]; ok {
+		return opts
 	}
+	return cfg.Probe
+}
 
-	// Some subfunctions in goroutines will need this pointer to store ping results
-	mp.pingData = data
+// Config returns round settings based on MultiPing fields
+func (mp *MultiPing) Config() RoundConfig {
+	return RoundConfig{
+		Timeout:     mp.Timeout,
+		Count:       mp.Count,
+		Interval:    mp.Interval,
+		FinishEarly: mp.FinishEarly,
+		Spread:      mp.Pacing.Spread,
+	}
+}
 
-	mp.ctx, mp.cancel = context.WithTimeout(context.Background(), mp.Timeout)
-	defer mp.cancel()
+// Ping is blocking function and pings all hosts in data.
+// It runs for (mp.Count-1)*mp.Interval + mp.Timeout time.
+func (mp *MultiPing) Ping(data *pingdata.PingData) {
+	mp.PingContext(context.Background(), data)
+}
 
-	// This goroutine depends on rxChan and no need to add it to workgroup
-	// It will terminate on channel close
-	go mp.batchProcessPacket()
+// PingContext pings all hosts in data mp.Count times and blocks until mp.Timeout passes
+// after the last echo request was sent or until ctx is cancelled.
+// Results are stored in data, packet counters of the round are returned in summary.
+// Returned error wraps ErrInvalidOption, ErrSocketSetup, ErrUnsupportedFamily or ErrPartialSend
+// or it is ctx.Err() if ctx was cancelled before round ended.
+func (mp *MultiPing) PingContext(ctx context.Context, data *pingdata.PingData) (RoundSummary, error) {
+	return mp.PingConfig(ctx, data, mp.Config())
+}
 
-	// 2 receiver goroutines: separate for IPv4 and IPv6
-	if mp.conn4 != nil {
-		mp.wg.Add(1)
-		mp.conn4.SetReadDeadline(time.Now().Add(mp.Timeout))
-		go mp.batchRecvICMP(pinger.ProtocolIpv4)
+// PingConfig is the same as PingContext, but uses cfg settings instead of MultiPing fields.
+// It can be called from several goroutines at the same time: rounds share the same sockets
+// and each round gets replies to its own echo requests. Concurrent rounds must use different data.
+func (mp *MultiPing) PingConfig(ctx context.Context, data *pingdata.PingData, cfg RoundConfig) (RoundSummary, error) {
+	if data.Count() == 0 {
+		return RoundSummary{}, nil
 	}
-	if mp.conn6 != nil {
-		mp.wg.Add(1)
-		mp.conn6.SetReadDeadline(time.Now().Add(mp.Timeout))
-		go mp.batchRecvICMP(pinger.ProtocolIpv6)
+
+	if err := ctx.Err(); err != nil {
+		return RoundSummary{}, err
 	}
 
-	// 2 Sender goroutine workers:
-	// one prepares message and other actually sends it
-	mp.wg.Add(1)
-	go mp.batchSendIcmp()
-	go mp.batchPrepareIcmp()
+	if cfg.Count < 1 {
+		cfg.Count = 1
+	}
+	if err := mp.check(&cfg); err != nil {
+		return RoundSummary{}, err
+	}
 
-	// wait for timeout and close connections
-	<-mp.ctx.Done()
-	mp.closeConnection()
+	sess, err := mp.acquire()
+	if err != nil {
+		return RoundSummary{}, fmt.Errorf("%w: %v", ErrSocketSetup, err)
+	}
+	defer mp.release(sess)
+
+	r := newRound(ctx, mp, sess, data, cfg)
+	defer r.cancel()
+	if err := r.setProber(); err != nil {
+		return RoundSummary{}, err
+	}
+	r.run()
+
+	return r.summary, r.err(ctx)
+}
 
-	// wait for all goroutines to terminate and cleanup
-	mp.wg.Wait()
-	mp.cleanup()
+// PingTimestamp pings hosts in data with ICMP timestamp requests instead of echo requests.
+// Besides RTT, host clock offset and one-way delays are stored in data, see PingStats.Clock.
+// Timestamp requests are IPv4 only, IPv6 hosts are reported as ErrUnsupportedFamily.
+// It requires privileged mode.
+func (mp *MultiPing) PingTimestamp(ctx context.Context, data *pingdata.PingData) (RoundSummary, error) {
+	cfg := mp.Config()
+	cfg.Timestamp = true
+	return mp.PingConfig(ctx, data, cfg)
 }
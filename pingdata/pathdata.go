@@ -0,0 +1,135 @@
+package pingdata
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// PathData holds per hop ping statistics of paths to hosts, like mtr does.
+// Use Add, Get and Iterate functions.
+type PathData struct {
+	entries map[netip.Addr]*PathStats
+}
+
+// PathStats is ping statistics of every hop on the path to a single host
+type PathStats struct {
+	hops    []*HopStats
+	reached int // TTL of the host itself, 0 if host has not replied yet
+}
+
+// HopStats is ping statistics of a single hop. Router is the last one, which replied with hop TTL.
+type HopStats struct {
+	PingStats
+	router netip.Addr
+}
+
+func NewPathData() *PathData {
+	return &PathData{
+		entries: make(map[netip.Addr]*PathStats),
+	}
+}
+
+// Add - adds some hosts to be traced
+func (pd *PathData) Add(hosts ...netip.Addr) {
+	for _, ip := range hosts {
+		pd.entries[ip] = &PathStats{}
+	}
+}
+
+// Del removes some hosts from trace list
+func (pd *PathData) Del(hosts ...netip.Addr) {
+	for _, ip := range hosts {
+		delete(pd.entries, ip)
+	}
+}
+
+// Reset statistics. Host list remains unchainged.
+func (pd *PathData) Reset() {
+	for _, e := range pd.entries {
+		e.Reset()
+	}
+}
+
+// Returns count of configured host
+func (pd *PathData) Count() int {
+	return len(pd.entries)
+}
+
+// Get searches for path statistics of a host
+func (pd *PathData) Get(ip netip.Addr) (*PathStats, bool) {
+	val, ok := pd.entries[ip]
+	return val, ok
+}
+
+// Iterate runs through all hosts and calls callback for stats processing
+func (pd *PathData) Iterate(callback func(ip netip.Addr, val *PathStats)) {
+	for key, val := range pd.entries {
+		callback(key, val)
+	}
+}
+
+func (pd *PathData) Dump(w io.Writer, title ...string) {
+	for _, l := range title {
+		w.Write([]byte(l))
+	}
+
+	pd.Iterate(func(ip netip.Addr, val *PathStats) {
+		w.Write([]byte(fmt.Sprintf("%s:\n", ip)))
+		for ttl, hop := range val.hops {
+			line := fmt.Sprintf("%3d %s: %s\n", ttl+1, hop.router, hop.String())
+			w.Write([]byte(line))
+		}
+	})
+}
+
+// Reset statistics and forget the path
+func (ps *PathStats) Reset() {
+	ps.hops = nil
+	ps.reached = 0
+}
+
+// Len returns count of known hops
+func (ps *PathStats) Len() int {
+	return len(ps.hops)
+}
+
+// Reached returns TTL of the host itself or 0, if host has not replied yet
+func (ps *PathStats) Reached() int {
+	return ps.reached
+}
+
+// SetReached registers that host replied to TTL. Hops behind it are forgotten.
+// Zero TTL means host is not reached anymore, e.g. path became longer.
+func (ps *PathStats) SetReached(ttl int) {
+	ps.reached = ttl
+	if ttl > 0 && len(ps.hops) > ttl {
+		ps.hops = ps.hops[:ttl]
+	}
+}
+
+// Hop returns statistics of hop with TTL starting with 1. Missing hops are created.
+func (ps *PathStats) Hop(ttl int) *HopStats {
+	for len(ps.hops) < ttl {
+		ps.hops = append(ps.hops, &HopStats{})
+	}
+	return ps.hops[ttl-1]
+}
+
+// Iterate runs through hops in TTL order
+func (ps *PathStats) Iterate(callback func(ttl int, hop *HopStats)) {
+	for i, hop := range ps.hops {
+		callback(i+1, hop)
+	}
+}
+
+// Router returns address of the last router, which replied with hop TTL.
+// It is not valid, when nobody replied yet.
+func (hs *HopStats) Router() netip.Addr {
+	return hs.router
+}
+
+// SetRouter registers router, which replied with hop TTL
+func (hs *HopStats) SetRouter(router netip.Addr) {
+	hs.router = router
+}
@@ -0,0 +1,49 @@
+package pingdata
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestPathStats(t *testing.T) {
+	host := netip.MustParseAddr("198.51.100.1")
+	router := netip.MustParseAddr("192.0.2.1")
+
+	data := NewPathData()
+	data.Add(host)
+	path, ok := data.Get(host)
+	if !ok || path.Len() != 0 || path.Reached() != 0 {
+		t.Fatal("Invalid initial path")
+	}
+
+	for ttl := 1; ttl <= 3; ttl++ {
+		path.Hop(ttl).Send(uint16(ttl))
+	}
+	hop := path.Hop(1)
+	if !hop.Recv(1, testRtt) {
+		t.Fatal("Hop reply was not matched")
+	}
+	hop.SetRouter(router)
+	if path.Len() != 3 || hop.Router() != router || hop.Loss() != 0 || path.Hop(2).Loss() != 1 {
+		t.Errorf("Invalid hop statistics")
+	}
+
+	// Host replied to TTL 2, further hops are forgotten
+	path.SetReached(2)
+	if path.Len() != 2 || path.Reached() != 2 {
+		t.Errorf("Invalid reached path len %d", path.Len())
+	}
+
+	var ttls []int
+	path.Iterate(func(ttl int, _ *HopStats) {
+		ttls = append(ttls, ttl)
+	})
+	if len(ttls) != 2 || ttls[0] != 1 || ttls[1] != 2 {
+		t.Errorf("Invalid hop iteration %v", ttls)
+	}
+
+	data.Reset()
+	if path.Len() != 0 || path.Reached() != 0 {
+		t.Errorf("Path was not reset")
+	}
+}
@@ -43,8 +43,41 @@ func (pr *PingData) Append(data *PingData) {
 					time.Duration(val.rx+stats.rx)
 			}
 			val.rtt = stats.rtt
+			if stats.minRtt > 0 && (val.minRtt == 0 || stats.minRtt < val.minRtt) {
+				val.minRtt = stats.minRtt
+			}
+			if stats.maxRtt > val.maxRtt {
+				val.maxRtt = stats.maxRtt
+			}
 			val.tx = val.tx + stats.tx
 			val.rx = val.rx + stats.rx
+			if stats.tosKnown {
+				val.tos = stats.tos
+				val.tosKnown = true
+			}
+			val.remarked = val.remarked + stats.remarked
+			val.corrupted = val.corrupted + stats.corrupted
+			val.truncated = val.truncated + stats.truncated
+			val.refused = val.refused + stats.refused
+			val.forged = val.forged + stats.forged
+			if stats.clockKnown {
+				val.SetClock(stats.offset, stats.forward, stats.back)
+			}
+			if stats.rcodeKnown {
+				val.rcode = stats.rcode
+				val.rcodeKnown = true
+			}
+			val.rcodeErrs = val.rcodeErrs + stats.rcodeErrs
+			if stats.ttl > 0 {
+				val.ttl = stats.ttl
+				val.distance = stats.distance
+			}
+			if stats.errs > 0 {
+				val.errs = val.errs + stats.errs
+				val.errType = stats.errType
+				val.errCode = stats.errCode
+				val.errRouter = stats.errRouter
+			}
 		} else {
 			pr.entries[ip] = stats
 		}
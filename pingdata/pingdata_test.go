@@ -46,9 +46,13 @@ func TestAppend(t *testing.T) {
 		rx:     1,
 		rtt:    100,
 		avgRtt: 100,
+		forged: 1,
 	}
 	data.entries[netip.MustParseAddr("192.168.1.2")] = &PingStats{
-		tx: 1,
+		tx:         1,
+		rcode:      2,
+		rcodeKnown: true,
+		rcodeErrs:  1,
 	}
 
 	// fake ping data #2
@@ -58,12 +62,16 @@ func TestAppend(t *testing.T) {
 		rx:     2,
 		rtt:    400,
 		avgRtt: 40,
+		forged: 2,
 	}
 	more.entries[netip.MustParseAddr("192.168.1.2")] = &PingStats{
-		tx:     1,
-		rx:     1,
-		rtt:    111,
-		avgRtt: 111,
+		tx:         1,
+		rx:         1,
+		rtt:        111,
+		avgRtt:     111,
+		rcode:      3,
+		rcodeKnown: true,
+		rcodeErrs:  1,
 	}
 	more.entries[netip.MustParseAddr("10.10.0.2")] = &PingStats{
 		tx:     1,
@@ -88,6 +96,7 @@ func TestAppend(t *testing.T) {
 		rx:     3,
 		rtt:    400,
 		avgRtt: 60,
+		forged: 3,
 	}) {
 		t.Errorf("Entry 1 is not equal")
 	}
@@ -96,10 +105,13 @@ func TestAppend(t *testing.T) {
 		t.Fatalf("Could not find expected entry 192.168.1.2")
 	}
 	if (*val != PingStats{
-		tx:     2,
-		rx:     1,
-		rtt:    111,
-		avgRtt: 111,
+		tx:         2,
+		rx:         1,
+		rtt:        111,
+		avgRtt:     111,
+		rcode:      3,
+		rcodeKnown: true,
+		rcodeErrs:  2,
 	}) {
 		t.Errorf("Entry 2 is not equal")
 	}
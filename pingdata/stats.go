@@ -2,27 +2,96 @@ package pingdata
 
 import (
 	"fmt"
+	"net/netip"
 	"time"
 )
 
+// Common initial TTL values of operating systems, used to infer hop distance
+var initialTTLs = [...]int{64, 128, 255}
+
+// Count of sequence numbers, that can be waited for at the same time.
+// Requests older than this window are forgotten and their late replies count as duplicates.
+const seqWindow = 64
+
 // A single host ping statistics
 type PingStats struct {
-	sequence uint16
+	sequence uint16 // first sequence number of outstanding requests window
+	pending  uint64 // outstanding requests bitmap. Bit N is set for sequence+N
 	tx       uint
 	rx       uint
 	dup      uint
 	rtt      time.Duration
 	avgRtt   time.Duration
+	minRtt   time.Duration
+	maxRtt   time.Duration
+
+	// ICMP error messages received instead of echo replies
+	errs      uint
+	errType   int
+	errCode   int
+	errRouter netip.Addr
+
+	// TTL of the last reply and hop distance inferred from it. Kept on Reset.
+	ttl      int
+	distance int
+
+	// TOS of the last reply and count of replies with DSCP different from sent one
+	tos      int
+	tosKnown bool
+	remarked uint
+
+	// Replies, which payload differs from sent one or is shorter. They are not counted as received.
+	corrupted uint
+	truncated uint
+
+	// Clock offset and one-way delays of the last ICMP timestamp reply
+	offset     time.Duration
+	forward    time.Duration
+	back       time.Duration
+	clockKnown bool
+
+	// Replies to port probes, which refused connection. They are counted as received too.
+	refused uint
+
+	// Response code of the last DNS answer and count of answers with error response code
+	rcode      int
+	rcodeKnown bool
+	rcodeErrs  uint
+
+	// Replies, which failed authentication. They are neither received nor lost.
+	forged uint
 }
 
-// Reset statistics to zero values
+// Reset statistics to zero values.
+// Last TTL and distance are kept, so that distance change between rounds can be detected.
 func (s *PingStats) Reset() {
 	s.tx = 0
 	s.rx = 0
 	s.dup = 0
 	s.sequence = 0
+	s.pending = 0
 	s.rtt = 0
 	s.avgRtt = 0
+	s.minRtt = 0
+	s.maxRtt = 0
+	s.errs = 0
+	s.errType = 0
+	s.errCode = 0
+	s.errRouter = netip.Addr{}
+	s.tos = 0
+	s.tosKnown = false
+	s.remarked = 0
+	s.corrupted = 0
+	s.truncated = 0
+	s.offset = 0
+	s.forward = 0
+	s.back = 0
+	s.clockKnown = false
+	s.refused = 0
+	s.rcode = 0
+	s.rcodeKnown = false
+	s.rcodeErrs = 0
+	s.forged = 0
 }
 
 func (s *PingStats) Valid() bool {
@@ -46,6 +115,16 @@ func (s *PingStats) Latency() float32 {
 	}
 }
 
+// Sent returns count of sent echo requests
+func (s *PingStats) Sent() uint {
+	return s.tx
+}
+
+// Received returns count of received echo replies
+func (s *PingStats) Received() uint {
+	return s.rx
+}
+
 func (s *PingStats) Duplicate() uint {
 	return s.dup
 }
@@ -55,28 +134,276 @@ func (s *PingStats) Rtt() time.Duration {
 	return s.rtt
 }
 
+// MinRtt returns minimal rtt of received packets
+func (s *PingStats) MinRtt() time.Duration {
+	return s.minRtt
+}
+
+// MaxRtt returns maximal rtt of received packets
+func (s *PingStats) MaxRtt() time.Duration {
+	return s.maxRtt
+}
+
+// TTL returns TTL (hop limit) of the last echo reply, 0 if unknown
+func (s *PingStats) TTL() int {
+	return s.ttl
+}
+
+// Distance returns hop distance to host inferred from the last reply TTL
+func (s *PingStats) Distance() int {
+	return s.distance
+}
+
+// DSCP returns DSCP bits of the last reply TOS (traffic class), -1 if unknown
+func (s *PingStats) DSCP() int {
+	if !s.tosKnown {
+		return -1
+	}
+	return s.tos >> 2
+}
+
+// ECN returns ECN bits of the last reply TOS (traffic class), -1 if unknown
+func (s *PingStats) ECN() int {
+	if !s.tosKnown {
+		return -1
+	}
+	return s.tos & 0x03
+}
+
+// Remarked returns count of replies, which came back with DSCP different from the sent one
+func (s *PingStats) Remarked() uint {
+	return s.remarked
+}
+
+// Refused returns count of port probe replies, which refused connection
+func (s *PingStats) Refused() uint {
+	return s.refused
+}
+
+// Forged returns count of replies, which failed authentication
+func (s *PingStats) Forged() uint {
+	return s.forged
+}
+
+// RecvForged registers reply, which failed authentication. Request is still waiting for reply.
+func (s *PingStats) RecvForged() {
+	s.forged++
+}
+
+// Corrupted returns count of replies, which payload differs from sent one
+func (s *PingStats) Corrupted() uint {
+	return s.corrupted
+}
+
+// Truncated returns count of replies with payload shorter than sent one
+func (s *PingStats) Truncated() uint {
+	return s.truncated
+}
+
+// Clock returns estimated offset of host clock from local clock and one-way delays
+// of the last ICMP timestamp reply. Delays are measured by both clocks, so they include offset.
+// ok is false, when no timestamp reply with standard timestamps was received.
+func (s *PingStats) Clock() (offset, forward, back time.Duration, ok bool) {
+	return s.offset, s.forward, s.back, s.clockKnown
+}
+
+// SetClock registers clock offset and one-way delays of ICMP timestamp reply
+func (s *PingStats) SetClock(offset, forward, back time.Duration) {
+	s.offset = offset
+	s.forward = forward
+	s.back = back
+	s.clockKnown = true
+}
+
+// Rcode returns response code of the last DNS answer, ok is false when no answer was received
+func (s *PingStats) Rcode() (rcode int, ok bool) {
+	return s.rcode, s.rcodeKnown
+}
+
+// RcodeErrors returns count of DNS answers with response code other than NOERROR (0).
+// They are counted as received too, as server has answered.
+func (s *PingStats) RcodeErrors() uint {
+	return s.rcodeErrs
+}
+
+// SetRcode registers response code of DNS answer
+func (s *PingStats) SetRcode(rcode int) {
+	s.rcode = rcode
+	s.rcodeKnown = true
+	if rcode != 0 {
+		s.rcodeErrs++
+	}
+}
+
+// Errors returns count of ICMP error messages received instead of echo replies
+func (s *PingStats) Errors() uint {
+	return s.errs
+}
+
+// LastError returns type and code of the last ICMP error message (e.g. destination unreachable)
+// and router, which sent it. ok is false, when no error was received.
+func (s *PingStats) LastError() (typ, code int, router netip.Addr, ok bool) {
+	return s.errType, s.errCode, s.errRouter, s.errs > 0
+}
+
 func (s *PingStats) String() string {
 	return fmt.Sprintf("tx=%d, rx=%d, rtt=%s, avgRtt=%s",
 		s.tx, s.rx, s.rtt, s.avgRtt)
 }
 
+// Send registers echo request with sequence seq.
+// Several requests may be outstanding at the same time.
 func (s *PingStats) Send(seq uint16) {
 	s.tx++
 	s.rtt = 0
-	s.sequence = seq
-}
 
-func (s *PingStats) Recv(seq uint16, rtt time.Duration) {
-	if s.sequence == seq {
-		s.rx++
-		s.rtt = rtt
-		if s.avgRtt == 0 {
-			s.avgRtt = rtt
+	if s.pending == 0 {
+		s.sequence = seq
+		s.pending = 1
+		return
+	}
+
+	offset := seq - s.sequence
+	if offset >= seqWindow {
+		// Slide window: oldest requests are forgotten
+		shift := offset - seqWindow + 1
+		if shift >= seqWindow {
+			s.pending = 0
 		} else {
-			s.avgRtt = (time.Duration(s.rx)*s.avgRtt + s.rtt) / time.Duration(s.rx+1)
+			s.pending >>= shift
 		}
-		s.sequence = 0
-	} else {
+		s.sequence += shift
+		offset -= shift
+	}
+	s.pending |= 1 << offset
+}
+
+// CancelSend reverts Send of request, which could not be sent after all.
+// Such request is not counted neither as sent nor as lost.
+func (s *PingStats) CancelSend(seq uint16) {
+	if s.resolve(seq) {
+		s.tx--
+	}
+}
+
+// Recv registers echo reply. Returns false if reply does not match
+// any outstanding request and is counted as duplicate.
+func (s *PingStats) Recv(seq uint16, rtt time.Duration) bool {
+	offset := seq - s.sequence
+	if offset >= seqWindow || s.pending&(1<<offset) == 0 {
 		s.dup++
+		return false
+	}
+
+	s.pending &^= 1 << offset
+	s.rx++
+	s.rtt = rtt
+	s.avgRtt = (time.Duration(s.rx-1)*s.avgRtt + rtt) / time.Duration(s.rx)
+	if s.minRtt == 0 || rtt < s.minRtt {
+		s.minRtt = rtt
+	}
+	if rtt > s.maxRtt {
+		s.maxRtt = rtt
+	}
+	return true
+}
+
+// RecvError registers ICMP error message sent by router instead of echo reply.
+// Request is no longer outstanding, but it still counts as lost.
+// Returns false if error does not match any outstanding request.
+func (s *PingStats) RecvError(seq uint16, typ, code int, router netip.Addr) bool {
+	if !s.resolve(seq) {
+		return false
+	}
+
+	s.errs++
+	s.errType = typ
+	s.errCode = code
+	s.errRouter = router
+	return true
+}
+
+// RecvRefused registers reply to port probe, which refused connection (e.g. TCP RST).
+// Host is up, so reply is counted as received.
+// Returns false if reply does not match any outstanding request and is counted as duplicate.
+func (s *PingStats) RecvRefused(seq uint16, rtt time.Duration) bool {
+	if !s.Recv(seq, rtt) {
+		return false
+	}
+	s.refused++
+	return true
+}
+
+// RecvCorrupted registers echo reply, which payload differs from sent one.
+// Request is no longer outstanding, but it still counts as lost.
+// Returns false if reply does not match any outstanding request.
+func (s *PingStats) RecvCorrupted(seq uint16) bool {
+	if !s.resolve(seq) {
+		return false
+	}
+	s.corrupted++
+	return true
+}
+
+// RecvTruncated registers echo reply, which payload is shorter than sent one.
+// Request is no longer outstanding, but it still counts as lost.
+// Returns false if reply does not match any outstanding request.
+func (s *PingStats) RecvTruncated(seq uint16) bool {
+	if !s.resolve(seq) {
+		return false
+	}
+	s.truncated++
+	return true
+}
+
+// resolve removes request seq from outstanding ones. Returns false if it is not outstanding.
+func (s *PingStats) resolve(seq uint16) bool {
+	offset := seq - s.sequence
+	if offset >= seqWindow || s.pending&(1<<offset) == 0 {
+		return false
+	}
+	s.pending &^= 1 << offset
+	return true
+}
+
+// SetTTL registers TTL (hop limit) of echo reply. Zero or negative TTL is unknown and ignored.
+// Returns true, when hop distance differs from distance of previous reply.
+func (s *PingStats) SetTTL(ttl int) bool {
+	if ttl <= 0 {
+		return false
+	}
+
+	known := s.ttl > 0
+	distance := Distance(ttl)
+	changed := known && distance != s.distance
+	s.ttl = ttl
+	s.distance = distance
+	return changed
+}
+
+// Distance infers hop count to host from reply TTL,
+// assuming host uses one of common initial TTL values: 64, 128 or 255.
+func Distance(ttl int) int {
+	for _, initial := range initialTTLs {
+		if ttl <= initial {
+			return initial - ttl
+		}
+	}
+	return 0
+}
+
+// SetTOS registers TOS (traffic class) of echo reply to request sent with sent TOS.
+// Negative tos is unknown and ignored. Returns true, when DSCP of reply differs from sent DSCP.
+func (s *PingStats) SetTOS(sent, tos int) bool {
+	if tos < 0 {
+		return false
+	}
+
+	s.tos = tos
+	s.tosKnown = true
+	if sent >= 0 && sent>>2 != tos>>2 {
+		s.remarked++
+		return true
 	}
+	return false
 }
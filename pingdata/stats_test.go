@@ -1,6 +1,7 @@
 package pingdata
 
 import (
+	"net/netip"
 	"testing"
 	"time"
 )
@@ -31,4 +32,206 @@ func TestPingStats(t *testing.T) {
 	if s.Duplicate() == 0 {
 		t.Fatal("Duplicates test failed")
 	}
+
+	s.Send(testSeq + 1)
+	s.CancelSend(testSeq + 1)
+	if s.Sent() != 1 || s.Loss() != 0 {
+		t.Fatalf("Cancelled request counted: sent %d, loss %f", s.Sent(), s.Loss())
+	}
+}
+
+func TestPingStatsWindow(t *testing.T) {
+	var s PingStats
+
+	// Several outstanding requests, sequence wraps around
+	for seq := uint16(0xfffe); seq != 3; seq++ {
+		s.Send(seq)
+	}
+	if s.Sent() != 5 {
+		t.Fatalf("Invalid sent count %d", s.Sent())
+	}
+
+	// Replies out of order
+	for _, seq := range []uint16{1, 0xffff, 0} {
+		if !s.Recv(seq, testRtt) {
+			t.Errorf("Reply %d was not matched", seq)
+		}
+	}
+	if s.Recv(1, testRtt) || s.Recv(3, testRtt) {
+		t.Errorf("Duplicate or unknown reply was matched")
+	}
+	if s.Received() != 3 || s.Duplicate() != 2 {
+		t.Errorf("Invalid counters: %s", s.String())
+	}
+
+	s.Recv(2, 3*testRtt)
+	if s.MinRtt() != testRtt || s.MaxRtt() != 3*testRtt || s.Latency() != float32(150) {
+		t.Errorf("Invalid rtt statistics min=%s max=%s avg=%f", s.MinRtt(), s.MaxRtt(), s.Latency())
+	}
+
+	// Requests out of window are forgotten
+	s.Reset()
+	for seq := uint16(0); seq < 2*seqWindow; seq++ {
+		s.Send(seq)
+	}
+	if s.Recv(0, testRtt) {
+		t.Errorf("Request outside window was matched")
+	}
+	if !s.Recv(2*seqWindow-1, testRtt) || !s.Recv(seqWindow, testRtt) {
+		t.Errorf("Request inside window was not matched")
+	}
+}
+
+func TestPingStatsError(t *testing.T) {
+	var s PingStats
+	router := netip.MustParseAddr("192.0.2.1")
+
+	s.Send(testSeq)
+	if _, _, _, ok := s.LastError(); ok {
+		t.Fatal("Unexpected initial error")
+	}
+	if !s.RecvError(testSeq, 3, 1, router) {
+		t.Fatal("Error was not matched")
+	}
+	if s.RecvError(testSeq, 3, 1, router) || s.Recv(testSeq, testRtt) {
+		t.Error("Request was answered twice")
+	}
+
+	typ, code, from, ok := s.LastError()
+	if !ok || typ != 3 || code != 1 || from != router || s.Errors() != 1 {
+		t.Errorf("Invalid error %d/%d from %s", typ, code, from)
+	}
+	if s.Loss() != 1 {
+		t.Errorf("Request with error must be lost: %f", s.Loss())
+	}
+}
+
+func TestPingStatsCorrupted(t *testing.T) {
+	var s PingStats
+
+	s.Send(testSeq)
+	s.Send(testSeq + 1)
+	if !s.RecvCorrupted(testSeq) || !s.RecvTruncated(testSeq+1) {
+		t.Fatal("Damaged replies were not matched")
+	}
+	if s.RecvCorrupted(testSeq) || s.Recv(testSeq+1, testRtt) {
+		t.Error("Request was answered twice")
+	}
+	if s.Corrupted() != 1 || s.Truncated() != 1 || s.Received() != 0 {
+		t.Errorf("Invalid counters: corrupted %d, truncated %d, received %d",
+			s.Corrupted(), s.Truncated(), s.Received())
+	}
+	if s.Loss() != 1 {
+		t.Errorf("Damaged replies must be lost: %f", s.Loss())
+	}
+
+	s.Reset()
+	if s.Corrupted() != 0 || s.Truncated() != 0 {
+		t.Error("Counters were not reset")
+	}
+}
+
+func TestPingStatsRefused(t *testing.T) {
+	var s PingStats
+
+	s.Send(testSeq)
+	if !s.RecvRefused(testSeq, testRtt) || s.RecvRefused(testSeq, testRtt) {
+		t.Fatal("Refused reply was not matched once")
+	}
+	if s.Received() != 1 || s.Refused() != 1 || s.Loss() != 0 {
+		t.Errorf("Refused reply must be received: received %d, refused %d", s.Received(), s.Refused())
+	}
+}
+
+func TestPingStatsClock(t *testing.T) {
+	var s PingStats
+
+	if _, _, _, ok := s.Clock(); ok {
+		t.Fatal("Unexpected initial clock")
+	}
+	s.SetClock(time.Second, 1010*time.Millisecond, -980*time.Millisecond)
+	offset, forward, back, ok := s.Clock()
+	if !ok || offset != time.Second || forward != 1010*time.Millisecond || back != -980*time.Millisecond {
+		t.Errorf("Invalid clock %s %s %s", offset, forward, back)
+	}
+
+	s.Reset()
+	if _, _, _, ok := s.Clock(); ok {
+		t.Error("Clock was not reset")
+	}
+}
+
+func TestPingStatsForged(t *testing.T) {
+	var s PingStats
+
+	s.Send(testSeq)
+	s.RecvForged()
+	if !s.Recv(testSeq, testRtt) {
+		t.Fatal("Reply after forged one was not matched")
+	}
+	if s.Forged() != 1 || s.Received() != 1 || s.Loss() != 0 {
+		t.Errorf("Forged reply must not count: forged %d, received %d", s.Forged(), s.Received())
+	}
+
+	s.Reset()
+	if s.Forged() != 0 {
+		t.Error("Forged was not reset")
+	}
+}
+
+func TestPingStatsRcode(t *testing.T) {
+	var s PingStats
+
+	if _, ok := s.Rcode(); ok {
+		t.Fatal("Unexpected initial rcode")
+	}
+	s.SetRcode(0)
+	s.SetRcode(2)
+	if rcode, ok := s.Rcode(); !ok || rcode != 2 || s.RcodeErrors() != 1 {
+		t.Errorf("Invalid rcode %d, errors %d", rcode, s.RcodeErrors())
+	}
+
+	s.Reset()
+	if _, ok := s.Rcode(); ok || s.RcodeErrors() != 0 {
+		t.Error("Rcode was not reset")
+	}
+}
+
+func TestPingStatsTTL(t *testing.T) {
+	for ttl, distance := range map[int]int{64: 0, 60: 4, 120: 8, 250: 5} {
+		if Distance(ttl) != distance {
+			t.Errorf("Invalid distance for TTL %d: %d", ttl, Distance(ttl))
+		}
+	}
+
+	var s PingStats
+	if s.SetTTL(60) || s.TTL() != 60 || s.Distance() != 4 {
+		t.Fatalf("Invalid first TTL %d distance %d", s.TTL(), s.Distance())
+	}
+	if s.SetTTL(60) || s.SetTTL(0) {
+		t.Errorf("Unchanged distance reported as change")
+	}
+
+	// Distance is kept between rounds
+	s.Reset()
+	if !s.SetTTL(58) || s.Distance() != 6 {
+		t.Errorf("Distance change was not detected")
+	}
+}
+
+func TestPingStatsTOS(t *testing.T) {
+	var s PingStats
+	if s.DSCP() != -1 || s.ECN() != -1 {
+		t.Fatal("Unknown TOS expected")
+	}
+
+	if s.SetTOS(46<<2, 46<<2|1) || s.DSCP() != 46 || s.ECN() != 1 {
+		t.Errorf("Invalid DSCP %d ECN %d", s.DSCP(), s.ECN())
+	}
+	if !s.SetTOS(46<<2, 0) || s.Remarked() != 1 || s.DSCP() != 0 {
+		t.Errorf("Remarking was not detected")
+	}
+	if s.SetTOS(46<<2, -1) || s.Remarked() != 1 {
+		t.Errorf("Unknown TOS must be ignored")
+	}
 }
@@ -0,0 +1,46 @@
+package pinger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"net/netip"
+)
+
+// MACSize is length of HMAC, which follows tracker in payload of authenticated echo requests
+const MACSize = 16
+
+// MinPayload returns the smallest payload: timestamp and tracker, and HMAC when Secret is set
+func (p *Pinger) MinPayload() int {
+	if len(p.Secret) > 0 {
+		return MinSize + MACSize
+	}
+	return MinSize
+}
+
+// mac returns truncated HMAC-SHA256 of echo request id, seq, timestamp, tracker and destination.
+// Datagram sockets replace id with socket port, so id is authenticated in privileged mode only.
+func (p *Pinger) mac(seq uint16, header []byte, dst netip.Addr) []byte {
+	var id uint16
+	if p.Privileged() {
+		id = p.id
+	}
+
+	h := hmac.New(sha256.New, p.Secret)
+	b := make([]byte, 4, 4+MinSize+16)
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], seq)
+	b = append(b, header[:MinSize]...)
+	dst16 := dst.Unmap().As16()
+	b = append(b, dst16[:]...)
+	h.Write(b)
+	return h.Sum(nil)[:MACSize]
+}
+
+// authentic checks HMAC of echo reply payload from src
+func (p *Pinger) authentic(seq uint16, data []byte, src netip.Addr) bool {
+	if len(data) < MinSize+MACSize {
+		return false
+	}
+	return hmac.Equal(data[MinSize:MinSize+MACSize], p.mac(seq, data, src))
+}
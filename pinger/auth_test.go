@@ -0,0 +1,52 @@
+package pinger
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseForged(t *testing.T) {
+	dst := netip.MustParseAddr("127.0.0.1")
+
+	for _, protocol := range []string{"icmp", "udp"} {
+		p := NewPinger("ip4", protocol, 77)
+		p.Secret = []byte("secret")
+		p.Pattern = Pattern{Kind: PatternIncrement}
+
+		pkt, err := p.PrepareICMPSize(dst, testSeq, MinSize)
+		if err != nil {
+			t.Fatalf("%s: Icmp prepare %s", protocol, err)
+		}
+		if len(pkt.Bytes) != echoHeaderLen+p.MinPayload() {
+			t.Fatalf("%s: payload without HMAC: %d bytes", protocol, len(pkt.Bytes)-echoHeaderLen)
+		}
+		// Echo reply is type 0, checksum is not verified on receive
+		pkt.Bytes[0] = 0
+
+		stats := p.ParsePacket(pkt)
+		if !stats.Valid || stats.Forged || stats.Corrupted || stats.Seq != testSeq {
+			t.Fatalf("%s: invalid authentic reply stats %+v", protocol, stats)
+		}
+
+		// Reply from other host
+		other := *pkt
+		other.Addr = netip.MustParseAddr("127.0.0.2")
+		if stats = p.ParsePacket(&other); !stats.Forged {
+			t.Errorf("%s: reply from other host is not forged", protocol)
+		}
+
+		// Shifted timestamp
+		forged := *pkt
+		forged.Bytes = append([]byte(nil), pkt.Bytes...)
+		forged.Bytes[echoHeaderLen+timeSliceLength-1]++
+		if stats = p.ParsePacket(&forged); !stats.Forged || stats.Seq != testSeq {
+			t.Errorf("%s: reply with changed timestamp is not forged: %+v", protocol, stats)
+		}
+
+		// Tracker without secret
+		p.Secret = []byte("other")
+		if stats = p.ParsePacket(pkt); !stats.Forged {
+			t.Errorf("%s: reply with other secret is not forged", protocol)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package pinger
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ListenConfig contains options for opening ICMP sockets.
+// Zero values mean OS defaults.
+type ListenConfig struct {
+	// Privileged opens raw ICMP socket, otherwise "unprivileged" datagram ICMP socket is used
+	Privileged bool
+
+	// Source address to bind to. Must be of the same family as socket
+	Source netip.Addr
+
+	// TTL (IPv6 hop limit) and TOS (IPv6 traffic class) of sent packets
+	TTL int
+	TOS int
+
+	// Socket receive and send buffer sizes in bytes
+	ReadBuffer  int
+	WriteBuffer int
+}
+
+// Conn is ICMP socket. Unlike icmp.PacketConn it can be configured
+// with socket options before it is used.
+type Conn struct {
+	proto    ProtocolVersion
+	datagram bool           // unprivileged datagram socket
+	recvTOS  bool           // TOS (traffic class) of received packets is known
+	pc       net.PacketConn // *net.IPConn, *net.UDPConn or wrapped *icmp.PacketConn
+	p4       *ipv4.PacketConn
+	p6       *ipv6.PacketConn
+
+	// Locks sends, while DF socket option is switched for a single packet
+	mu sync.Mutex
+}
+
+// Listen opens ICMP socket of proto version
+func (lc *ListenConfig) Listen(proto ProtocolVersion) (*Conn, error) {
+	source := lc.Source
+	if source.IsValid() && (source.Is4() != (proto == ProtocolIpv4)) {
+		return nil, fmt.Errorf("%w: source %s does not match IPv%d", ErrInvalidAddr, source, proto)
+	}
+
+	c := &Conn{
+		proto:    proto,
+		datagram: !lc.Privileged,
+	}
+
+	var err error
+	if lc.Privileged {
+		network := "ip4:icmp"
+		if proto == ProtocolIpv6 {
+			network = "ip6:ipv6-icmp"
+		}
+		address := ""
+		if source.IsValid() {
+			address = source.String()
+		}
+		c.pc, err = net.ListenPacket(network, address)
+	} else {
+		c.pc, err = listenDatagram(proto, source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.setup(lc); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// setup applies socket options
+func (c *Conn) setup(lc *ListenConfig) error {
+	if buf, ok := c.pc.(interface {
+		SetReadBuffer(int) error
+		SetWriteBuffer(int) error
+	}); ok {
+		if lc.ReadBuffer > 0 {
+			if err := buf.SetReadBuffer(lc.ReadBuffer); err != nil {
+				return err
+			}
+		}
+		if lc.WriteBuffer > 0 {
+			if err := buf.SetWriteBuffer(lc.WriteBuffer); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.proto == ProtocolIpv4 {
+		c.p4 = ipv4.NewPacketConn(c.pc)
+		if lc.TTL > 0 {
+			if err := c.p4.SetTTL(lc.TTL); err != nil {
+				return err
+			}
+		}
+		if lc.TOS > 0 {
+			if err := c.p4.SetTOS(lc.TOS); err != nil {
+				return err
+			}
+		}
+		switch pc := c.pc.(type) {
+		case *net.IPConn:
+			// TOS is read from IPv4 header
+			c.recvTOS = true
+		case *net.UDPConn:
+			c.recvTOS = enableRecvTOS(pc) == nil
+		}
+		return c.p4.SetControlMessage(ipv4.FlagTTL, true)
+	}
+
+	c.p6 = ipv6.NewPacketConn(c.pc)
+	if lc.TTL > 0 {
+		if err := c.p6.SetHopLimit(lc.TTL); err != nil {
+			return err
+		}
+	}
+	if lc.TOS > 0 {
+		if err := c.p6.SetTrafficClass(lc.TOS); err != nil {
+			return err
+		}
+	}
+	// Hop limit and traffic class are nice to have, IPv6 should work without them
+	c.p6.SetControlMessage(ipv6.FlagHopLimit, true)
+	c.recvTOS = c.p6.SetControlMessage(ipv6.FlagTrafficClass, true) == nil
+	return nil
+}
+
+// wrapConn converts externally opened icmp.PacketConn
+func wrapConn(ic *icmp.PacketConn, proto ProtocolVersion, privileged bool) *Conn {
+	if ic == nil {
+		return nil
+	}
+	return &Conn{
+		proto:    proto,
+		datagram: !privileged,
+		pc:       ic,
+		p4:       ic.IPv4PacketConn(),
+		p6:       ic.IPv6PacketConn(),
+	}
+}
+
+// Close closes connection. Blocked reads are unblocked with error.
+func (c *Conn) Close() error {
+	return c.pc.Close()
+}
+
+// LocalAddr returns socket local address
+func (c *Conn) LocalAddr() net.Addr {
+	return c.pc.LocalAddr()
+}
+
+// SetReadDeadline sets the deadline for future reads
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.pc.SetReadDeadline(t)
+}
+
+// readFrom reads ICMP message and returns its source, TTL (hop limit) and TOS (traffic class).
+// TOS is -1, when it is unknown.
+func (c *Conn) readFrom(b []byte) (n, ttl, tos int, src net.Addr, err error) {
+	tos = -1
+	if c.proto == ProtocolIpv4 {
+		if c.recvTOS {
+			switch pc := c.pc.(type) {
+			case *net.IPConn:
+				return readRaw4(pc, b)
+			case *net.UDPConn:
+				return readDatagram4(pc, b)
+			}
+		}
+
+		var cm *ipv4.ControlMessage
+		n, cm, src, err = c.p4.ReadFrom(b)
+		if cm != nil {
+			ttl = cm.TTL
+		}
+		return
+	}
+
+	var cm *ipv6.ControlMessage
+	n, cm, src, err = c.p6.ReadFrom(b)
+	if cm != nil {
+		ttl = cm.HopLimit
+		if c.recvTOS {
+			tos = cm.TrafficClass
+		}
+	}
+	return
+}
+
+// readRaw4 reads ICMP message from IPv4 raw socket. TTL and TOS are taken from IPv4 header,
+// which is removed from b afterwards.
+func readRaw4(pc *net.IPConn, b []byte) (n, ttl, tos int, src net.Addr, err error) {
+	n, _, _, addr, err := pc.ReadMsgIP(b, nil)
+	if err != nil {
+		return 0, 0, -1, nil, err
+	}
+
+	// Some platforms strip header anyway
+	if n < ipv4HeaderLen || b[0]>>4 != 4 {
+		return n, 0, -1, addr, nil
+	}
+	hdrLen := int(b[0]&0x0f) << 2
+	if hdrLen < ipv4HeaderLen || hdrLen > n {
+		return n, 0, -1, addr, nil
+	}
+
+	tos, ttl = int(b[1]), int(b[8])
+	n = copy(b, b[hdrLen:n])
+	return n, ttl, tos, addr, nil
+}
+
+// writeTo sends packet bytes to packet address. Positive packet TTL and TOS override
+// socket TTL (hop limit) and TOS (traffic class) for this packet.
+// DF is set on socket just for this packet, when packet DontFragment is set.
+func (c *Conn) writeTo(pkt *Packet) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !pkt.DontFragment {
+		return c.write(pkt.Bytes, pkt.Addr, pkt.TTL, pkt.TOS)
+	}
+
+	sc, ok := c.pc.(syscall.Conn)
+	if !ok {
+		return 0, ErrPacketOptions
+	}
+	restore, err := setDontFragment(sc, c.proto)
+	if err != nil {
+		return 0, err
+	}
+	defer restore()
+	return c.write(pkt.Bytes, pkt.Addr, pkt.TTL, pkt.TOS)
+}
+
+// write sends ICMP message to addr with ttl and tos
+func (c *Conn) write(b []byte, addr netip.Addr, ttl, tos int) (int, error) {
+	var dst net.Addr
+	if c.datagram {
+		dst = &net.UDPAddr{IP: addr.AsSlice(), Zone: addr.Zone()}
+	} else {
+		dst = &net.IPAddr{IP: addr.AsSlice(), Zone: addr.Zone()}
+	}
+	if ttl <= 0 && tos <= 0 {
+		return c.pc.WriteTo(b, dst)
+	}
+
+	oob, err := c.control(ttl, tos)
+	if err != nil {
+		return 0, err
+	}
+
+	switch pc := c.pc.(type) {
+	case *net.UDPConn:
+		n, _, err := pc.WriteMsgUDP(b, oob, dst.(*net.UDPAddr))
+		return n, err
+	case *net.IPConn:
+		n, _, err := pc.WriteMsgIP(b, oob, dst.(*net.IPAddr))
+		return n, err
+	}
+	// Connections opened outside of ListenConfig
+	return 0, ErrPacketOptions
+}
+
+// control returns control message, which sets ttl and tos of sent packet
+func (c *Conn) control(ttl, tos int) ([]byte, error) {
+	if c.proto == ProtocolIpv4 {
+		return ipv4Control(ttl, tos)
+	}
+	return (&ipv6.ControlMessage{HopLimit: ttl, TrafficClass: tos}).Marshal(), nil
+}
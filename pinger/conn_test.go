@@ -0,0 +1,104 @@
+package pinger
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestPacketTTL(t *testing.T) {
+	lc := ListenConfig{Privileged: true}
+	conn, err := lc.Listen(ProtocolIpv4)
+	if err != nil {
+		t.Skipf("Raw socket is not available: %s", err)
+	}
+	defer conn.Close()
+
+	p := NewPinger("ip", "icmp", 444)
+	p.SetPrivileged(true)
+	p.SetSockets(conn, nil)
+
+	pkt, err := p.PrepareICMP(netip.MustParseAddr("127.0.0.1"), testSeq)
+	if err != nil {
+		t.Fatalf("Icmp prepare %s", err)
+	}
+	pkt.TTL = 7
+	if err = p.SendPacket(pkt); err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+
+	// Raw socket receives own echo request sent over loopback
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		recv, err := p.RecvPacket(ProtocolIpv4)
+		if err != nil {
+			t.Fatalf("Echo request was not received: %s", err)
+		}
+		m, err := icmp.ParseMessage(ProtocolICMP, recv.Bytes[:recv.Len])
+		if err != nil || m.Type != ipv4.ICMPTypeEcho {
+			continue
+		}
+		if echo, ok := m.Body.(*icmp.Echo); ok && echo.ID == 444 && echo.Seq == testSeq {
+			if recv.TTL != 7 {
+				t.Errorf("Invalid TTL %d", recv.TTL)
+			}
+			return
+		}
+	}
+}
+
+func TestPacketTOS(t *testing.T) {
+	lc := ListenConfig{Privileged: true}
+	conn, err := lc.Listen(ProtocolIpv4)
+	if err != nil {
+		t.Skipf("Raw socket is not available: %s", err)
+	}
+	defer conn.Close()
+
+	// Reading with ReadMsgIP keeps IPv4 header
+	sniffer, err := net.ListenIP("ip4:icmp", &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Raw socket failed: %s", err)
+	}
+	defer sniffer.Close()
+
+	p := NewPinger("ip", "icmp", 555)
+	p.SetPrivileged(true)
+	p.SetSockets(conn, nil)
+
+	pkt, err := p.PrepareICMP(netip.MustParseAddr("127.0.0.1"), testSeq)
+	if err != nil {
+		t.Fatalf("Icmp prepare %s", err)
+	}
+	pkt.TTL, pkt.TOS = 9, 46<<2
+	if err = p.SendPacket(pkt); err != nil {
+		t.Fatalf("Send failed: %s", err)
+	}
+
+	sniffer.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 512)
+	for {
+		n, _, _, _, err := sniffer.ReadMsgIP(b, nil)
+		if err != nil {
+			t.Fatalf("Echo request was not received: %s", err)
+		}
+		h, err := ipv4.ParseHeader(b[:n])
+		if err != nil {
+			continue
+		}
+		m, err := icmp.ParseMessage(ProtocolICMP, b[h.Len:n])
+		if err != nil || m.Type != ipv4.ICMPTypeEcho {
+			continue
+		}
+		if echo, ok := m.Body.(*icmp.Echo); ok && echo.ID == 555 && echo.Seq == testSeq {
+			if h.TOS != 46<<2 || h.TTL != 9 {
+				t.Errorf("Invalid TOS %d TTL %d", h.TOS, h.TTL)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package pinger
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// ipv4Control returns IP_TTL and IP_TOS control messages for sendmsg, zero values are skipped.
+// golang.org/x/net/ipv4 can marshal only packet info, so they are built here.
+func ipv4Control(ttl, tos int) ([]byte, error) {
+	var b []byte
+	if ttl > 0 {
+		b = appendControl(b, syscall.IP_TTL, ttl)
+	}
+	if tos > 0 {
+		b = appendControl(b, syscall.IP_TOS, tos)
+	}
+	return b, nil
+}
+
+// appendControl appends IPPROTO_IP control message with int value
+func appendControl(b []byte, typ int32, value int) []byte {
+	off := len(b)
+	b = append(b, make([]byte, syscall.CmsgSpace(4))...)
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[off]))
+	h.Level = syscall.IPPROTO_IP
+	h.Type = typ
+	h.SetLen(syscall.CmsgLen(4))
+	*(*int32)(unsafe.Pointer(&b[off+syscall.CmsgLen(0)])) = int32(value)
+	return b
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package pinger
+
+// ipv4Control is not supported, IPv4 TTL and TOS can't be set per packet
+func ipv4Control(ttl, tos int) ([]byte, error) {
+	return nil, ErrPacketOptions
+}
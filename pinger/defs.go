@@ -7,6 +7,15 @@ const (
 	trackerLength    = 8
 	ProtocolICMP     = 1
 	ProtocolIPv6ICMP = 58
+	ProtocolUDP      = 17
+
+	// MinSize is the smallest payload: timestamp and tracker
+	MinSize = timeSliceLength + trackerLength
+	// MaxSize is the largest payload, which fits into IPv4 packet
+	MaxSize = 65535 - 20 - 8
+
+	// maxPacketSize is the largest IP packet (IPv6 payload) length
+	maxPacketSize = 65535
 )
 
 type ProtocolVersion int
@@ -19,4 +28,7 @@ const (
 var (
 	ErrInvalidConn = errors.New("invalid connection")
 	ErrInvalidAddr = errors.New("invalid address")
+
+	// ErrPacketOptions is returned, when packet TTL can't be set on this connection or platform
+	ErrPacketOptions = errors.New("per-packet options are not supported")
 )
@@ -0,0 +1,48 @@
+package pinger
+
+import (
+	"syscall"
+)
+
+// DontFragmentSupported tells, whether DF bit can be set on this platform, see Packet.DontFragment
+const DontFragmentSupported = true
+
+// setDontFragment switches socket to path MTU probing mode: DF is set and path MTU cache is ignored,
+// so packets larger than cached path MTU are sent too. Previous mode is restored with restore.
+func setDontFragment(sc syscall.Conn, proto ProtocolVersion) (restore func() error, err error) {
+	level, opt, probe := syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE
+	if proto == ProtocolIpv6 {
+		level, opt, probe = syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_PROBE
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var prev int
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		prev, serr = syscall.GetsockoptInt(int(fd), level, opt)
+		if serr == nil {
+			serr = syscall.SetsockoptInt(int(fd), level, opt, probe)
+		}
+	})
+	if err == nil {
+		err = serr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		var serr error
+		err := raw.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptInt(int(fd), level, opt, prev)
+		})
+		if err != nil {
+			return err
+		}
+		return serr
+	}, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package pinger
+
+import "syscall"
+
+// DontFragmentSupported tells, whether DF bit can be set on this platform, see Packet.DontFragment
+const DontFragmentSupported = false
+
+// setDontFragment is not supported, DF bit can't be set
+func setDontFragment(sc syscall.Conn, proto ProtocolVersion) (restore func() error, err error) {
+	return nil, ErrPacketOptions
+}
@@ -0,0 +1,166 @@
+package pinger
+
+import (
+	"fmt"
+	"net/netip"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Interface identification sub-types and address families of RFC 8335 extended echo request
+const (
+	interfaceByName    = 1
+	interfaceByIndex   = 2
+	interfaceByAddress = 3
+
+	afiIPv4 = 1
+	afiIPv6 = 2
+)
+
+// Codes of ICMP extended echo reply
+const (
+	ExtendedNoError     = 0 // interface was found, see ExtendedEcho flags
+	ExtendedMalformed   = 1 // malformed query
+	ExtendedNoInterface = 2 // no such interface
+	ExtendedNoEntry     = 3 // no such neighbor table entry
+	ExtendedMultiple    = 4 // multiple interfaces satisfy query
+)
+
+// InterfaceQuery identifies interface, which state is requested with ICMP extended echo (RFC 8335).
+// Exactly one of Name, Index and Addr must be set.
+type InterfaceQuery struct {
+	Name  string     // interface name, e.g. "eth0"
+	Index int        // interface index
+	Addr  netip.Addr // interface address
+
+	// Addr belongs to neighbor of probed node and not to the node itself
+	Neighbor bool
+}
+
+// Validate checks, that query identifies interface
+func (q InterfaceQuery) Validate() error {
+	_, err := q.ident()
+	return err
+}
+
+// ident returns interface identification object of query
+func (q InterfaceQuery) ident() (*icmp.InterfaceIdent, error) {
+	set := 0
+	ident := &icmp.InterfaceIdent{}
+	if q.Name != "" {
+		set++
+		ident.Type, ident.Name = interfaceByName, q.Name
+	}
+	if q.Index > 0 {
+		set++
+		ident.Type, ident.Index = interfaceByIndex, q.Index
+	}
+	if q.Addr.IsValid() {
+		set++
+		ident.Type, ident.Addr = interfaceByAddress, q.Addr.Unmap().AsSlice()
+		ident.AFI = afiIPv4
+		if q.Addr.Unmap().Is6() {
+			ident.AFI = afiIPv6
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("%w: interface query must set one of name, index and address", ErrInvalidAddr)
+	}
+	if q.Neighbor && !q.Addr.IsValid() {
+		return nil, fmt.Errorf("%w: neighbor is identified by address only", ErrInvalidAddr)
+	}
+	return ident, nil
+}
+
+// ExtendedEcho is a reply to ICMP extended echo request. It tells state of queried interface.
+type ExtendedEcho struct {
+	Code   int  // ExtendedNoError or error code
+	State  int  // neighbor reachability state, when Neighbor was queried
+	Active bool // interface is active
+	IPv4   bool // interface runs IPv4
+	IPv6   bool // interface runs IPv6
+}
+
+// Found returns, whether queried interface was found
+func (e *ExtendedEcho) Found() bool {
+	return e.Code == ExtendedNoError
+}
+
+func (e *ExtendedEcho) String() string {
+	switch e.Code {
+	case ExtendedNoError:
+		return fmt.Sprintf("active %v, IPv4 %v, IPv6 %v", e.Active, e.IPv4, e.IPv6)
+	case ExtendedMalformed:
+		return "malformed query"
+	case ExtendedNoInterface:
+		return "no such interface"
+	case ExtendedNoEntry:
+		return "no such table entry"
+	case ExtendedMultiple:
+		return "multiple interfaces satisfy query"
+	}
+	return fmt.Sprintf("code %d", e.Code)
+}
+
+// PrepareExtendedEcho prepares ICMP extended echo request (RFC 8335), which asks addr about interface
+// identified by query. Request has 8 bit sequence only, so upper byte of seq is not sent.
+// Reply has no payload, so caller measures RTT itself.
+func (p *Pinger) PrepareExtendedEcho(addr netip.Addr, seq uint16, query InterfaceQuery) (*Packet, error) {
+	ident, err := query.ident()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &icmp.Message{
+		Body: &icmp.ExtendedEchoRequest{
+			ID:         int(p.id),
+			Seq:        int(seq & 0xff),
+			Local:      !query.Neighbor,
+			Extensions: []icmp.Extension{ident},
+		},
+	}
+
+	var proto ProtocolVersion
+	if addr.Is4() {
+		msg.Type = ipv4.ICMPTypeExtendedEchoRequest
+		proto = ProtocolIpv4
+	} else if addr.Is6() {
+		msg.Type = ipv6.ICMPTypeExtendedEchoRequest
+		proto = ProtocolIpv6
+	} else {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAddr, addr)
+	}
+
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Packet{Proto: proto, Bytes: b, Len: len(b), Seq: seq & 0xff, Addr: addr}, nil
+}
+
+// parseExtendedEcho parses ICMP extended echo reply
+func (p *Pinger) parseExtendedEcho(m *icmp.Message, ret IcmpStats) IcmpStats {
+	body, ok := m.Body.(*icmp.ExtendedEchoReply)
+	if !ok {
+		ret.Valid = false
+		return ret
+	}
+
+	// Datagram sockets rewrite id, kernel matches replies itself
+	if p.Privileged() && uint16(body.ID) != p.id {
+		ret.Valid = false
+		return ret
+	}
+
+	ret.Seq = uint16(body.Seq)
+	ret.Extended = &ExtendedEcho{
+		Code:   m.Code,
+		State:  body.State,
+		Active: body.Active,
+		IPv4:   body.IPv4,
+		IPv6:   body.IPv6,
+	}
+	return ret
+}
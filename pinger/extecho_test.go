@@ -0,0 +1,97 @@
+package pinger
+
+import (
+	"net/netip"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestPrepareExtendedEcho(t *testing.T) {
+	p := NewPinger("ip4", "icmp", 77)
+	target := netip.MustParseAddr("192.0.2.1")
+
+	queries := []struct {
+		query InterfaceQuery
+		ident icmp.InterfaceIdent
+		local bool
+	}{
+		{InterfaceQuery{Name: "eth0"}, icmp.InterfaceIdent{Type: interfaceByName, Name: "eth0"}, true},
+		{InterfaceQuery{Index: 3}, icmp.InterfaceIdent{Type: interfaceByIndex, Index: 3}, true},
+		{InterfaceQuery{Addr: netip.MustParseAddr("10.0.0.1"), Neighbor: true},
+			icmp.InterfaceIdent{Type: interfaceByAddress, AFI: afiIPv4, Addr: []byte{10, 0, 0, 1}}, false},
+	}
+	for _, q := range queries {
+		pkt, err := p.PrepareExtendedEcho(target, 0x1234, q.query)
+		if err != nil {
+			t.Fatalf("%+v: prepare failed: %s", q.query, err)
+		}
+		if pkt.Seq != 0x34 {
+			t.Errorf("%+v: sequence %#x is not 8 bit", q.query, pkt.Seq)
+		}
+
+		m, err := icmp.ParseMessage(ProtocolICMP, pkt.Bytes)
+		if err != nil || m.Type != ipv4.ICMPTypeExtendedEchoRequest {
+			t.Fatalf("%+v: invalid request %v: %v", q.query, m, err)
+		}
+		req := m.Body.(*icmp.ExtendedEchoRequest)
+		if req.ID != 77 || req.Seq != 0x34 || req.Local != q.local || len(req.Extensions) != 1 {
+			t.Fatalf("%+v: invalid request %+v", q.query, req)
+		}
+		ident, ok := req.Extensions[0].(*icmp.InterfaceIdent)
+		if !ok || ident.Type != q.ident.Type || ident.Name != q.ident.Name || ident.Index != q.ident.Index ||
+			ident.AFI != q.ident.AFI || string(ident.Addr) != string(q.ident.Addr) {
+			t.Errorf("%+v: invalid interface identification %+v", q.query, req.Extensions[0])
+		}
+	}
+
+	for _, query := range []InterfaceQuery{{}, {Name: "eth0", Index: 1}, {Index: 1, Neighbor: true}} {
+		if _, err := p.PrepareExtendedEcho(target, 1, query); err == nil {
+			t.Errorf("%+v: invalid query prepared", query)
+		}
+	}
+
+	pkt, err := p.PrepareExtendedEcho(netip.MustParseAddr("2001:db8::1"), 1, InterfaceQuery{Name: "lo"})
+	if err != nil || pkt.Proto != ProtocolIpv6 || pkt.Bytes[0] != byte(ipv6.ICMPTypeExtendedEchoRequest) {
+		t.Errorf("Invalid IPv6 request %+v: %v", pkt, err)
+	}
+}
+
+func TestParseExtendedEcho(t *testing.T) {
+	p := NewPinger("ip4", "icmp", 77)
+
+	reply := func(id, code int) *Packet {
+		m := icmp.Message{
+			Type: ipv4.ICMPTypeExtendedEchoReply,
+			Code: code,
+			Body: &icmp.ExtendedEchoReply{ID: id, Seq: 0x34, Active: true, IPv4: true},
+		}
+		b, err := m.Marshal(nil)
+		if err != nil {
+			t.Fatalf("Marshal reply: %s", err)
+		}
+		return &Packet{Proto: ProtocolIpv4, Bytes: b, Len: len(b), Addr: netip.MustParseAddr("192.0.2.1")}
+	}
+
+	stats := p.ParsePacket(reply(77, ExtendedNoError))
+	if !stats.Valid || stats.Seq != 0x34 || stats.Extended == nil {
+		t.Fatalf("Invalid extended reply stats %+v", stats)
+	}
+	if ext := stats.Extended; !ext.Found() || !ext.Active || !ext.IPv4 || ext.IPv6 {
+		t.Errorf("Invalid interface state %s", ext)
+	}
+
+	if stats = p.ParsePacket(reply(77, ExtendedNoInterface)); !stats.Valid || stats.Extended.Found() {
+		t.Errorf("Missing interface found: %+v", stats.Extended)
+	}
+
+	if p.ParsePacket(reply(78, ExtendedNoError)).Valid {
+		t.Error("Reply to other id accepted")
+	}
+	p.SetPrivileged(false)
+	if !p.ParsePacket(reply(78, ExtendedNoError)).Valid {
+		t.Error("Reply rejected by unprivileged pinger, though kernel rewrites id")
+	}
+}
@@ -0,0 +1,175 @@
+package pinger
+
+import (
+	"encoding/binary"
+	"net/netip"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	echoHeaderLen = 8
+
+	codeFragmentationNeeded = 4 // code of IPv4 destination unreachable
+	codePortUnreachable     = 3 // code of IPv4 destination unreachable
+	codePortUnreachable6    = 4 // code of IPv6 destination unreachable
+)
+
+// quotedData returns original datagram quoted by ICMP error message
+func quotedData(m *icmp.Message) ([]byte, bool) {
+	switch body := m.Body.(type) {
+	case *icmp.DstUnreach:
+		return body.Data, true
+	case *icmp.TimeExceeded:
+		return body.Data, true
+	case *icmp.PacketTooBig:
+		return body.Data, true
+	case *icmp.ParamProb:
+		return body.Data, true
+	}
+	return nil, false
+}
+
+// icmpType returns numeric ICMP type of message
+func icmpType(m *icmp.Message) int {
+	switch t := m.Type.(type) {
+	case ipv4.ICMPType:
+		return int(t)
+	case ipv6.ICMPType:
+		return int(t)
+	}
+	return -1
+}
+
+// parseQuoted parses quoted IP header. Returns destination, transport protocol
+// and at least 8 bytes of transport header of original datagram.
+func parseQuoted(proto ProtocolVersion, b []byte) (dst netip.Addr, protocol byte, hdr []byte, ok bool) {
+	if proto == ProtocolIpv4 {
+		if len(b) < ipv4HeaderLen || b[0]>>4 != 4 {
+			return dst, 0, nil, false
+		}
+		ihl := int(b[0]&0x0f) * 4
+		if ihl < ipv4HeaderLen || len(b) < ihl+echoHeaderLen {
+			return dst, 0, nil, false
+		}
+		dst, _ = netip.AddrFromSlice(b[16:20])
+		return dst, b[9], b[ihl:], true
+	}
+
+	// Extension headers are not expected in probes
+	if len(b) < ipv6HeaderLen+echoHeaderLen || b[0]>>4 != 6 {
+		return dst, 0, nil, false
+	}
+	dst, _ = netip.AddrFromSlice(b[24:40])
+	return dst, b[6], b[ipv6HeaderLen:], true
+}
+
+// parseQuotedEcho parses quoted IP header and echo (or IPv4 timestamp) request header.
+// Returns destination, id and sequence of original request.
+func parseQuotedEcho(proto ProtocolVersion, b []byte) (dst netip.Addr, id, seq uint16, ok bool) {
+	dst, protocol, icmpHdr, ok := parseQuoted(proto, b)
+	if !ok {
+		return dst, 0, 0, false
+	}
+
+	echoType := byte(ipv4.ICMPTypeEcho)
+	timestamp := icmpHdr[0] == byte(ipv4.ICMPTypeTimestamp)
+	if proto == ProtocolIpv6 {
+		echoType = byte(ipv6.ICMPTypeEchoRequest)
+		timestamp = false
+	}
+	if protocol != icmpProtocol(proto) || (icmpHdr[0] != echoType && !timestamp) {
+		return dst, 0, 0, false
+	}
+
+	id = binary.BigEndian.Uint16(icmpHdr[4:6])
+	seq = binary.BigEndian.Uint16(icmpHdr[6:8])
+	return dst, id, seq, true
+}
+
+// parseQuotedUDP parses quoted IP header and UDP header.
+// Returns destination, source and destination ports of original datagram.
+func parseQuotedUDP(proto ProtocolVersion, b []byte) (dst netip.Addr, srcPort, dstPort uint16, ok bool) {
+	dst, protocol, udpHdr, ok := parseQuoted(proto, b)
+	if !ok || protocol != ProtocolUDP {
+		return dst, 0, 0, false
+	}
+	return dst, binary.BigEndian.Uint16(udpHdr[0:2]), binary.BigEndian.Uint16(udpHdr[2:4]), true
+}
+
+// icmpProtocol returns IP protocol number of ICMP of proto version
+func icmpProtocol(proto ProtocolVersion) byte {
+	if proto == ProtocolIpv6 {
+		return ProtocolIPv6ICMP
+	}
+	return ProtocolICMP
+}
+
+// nextHopMTU returns MTU reported by IPv4 fragmentation needed or IPv6 packet too big message.
+// b is the whole ICMP message, IPv4 MTU is in its otherwise unused header field (RFC 1191).
+func nextHopMTU(b []byte, m *icmp.Message) int {
+	if body, ok := m.Body.(*icmp.PacketTooBig); ok {
+		return body.MTU
+	}
+	if m.Type == ipv4.ICMPTypeDestinationUnreachable && m.Code == codeFragmentationNeeded && len(b) >= echoHeaderLen {
+		return int(binary.BigEndian.Uint16(b[6:8]))
+	}
+	return 0
+}
+
+// parseUDPError fills ret with ICMP error message about UDP datagram.
+// Sequence is unknown, so caller matches error by ports.
+func (p *Pinger) parseUDPError(recv *Packet, b []byte, m *icmp.Message, data []byte, ret IcmpStats) IcmpStats {
+	dst, srcPort, dstPort, ok := parseQuotedUDP(recv.Proto, data)
+	if !ok {
+		ret.Valid = false
+		return ret
+	}
+
+	ret.Error = &IcmpError{
+		Type:       icmpType(m),
+		Code:       m.Code,
+		Router:     recv.Addr,
+		Dest:       dst,
+		MTU:        nextHopMTU(b, m),
+		UDP:        true,
+		SourcePort: srcPort,
+		Port:       dstPort,
+	}
+	return ret
+}
+
+// parseError fills ret with ICMP error message about our echo request or UDP datagram.
+// b is the whole ICMP message.
+func (p *Pinger) parseError(recv *Packet, b []byte, m *icmp.Message, ret IcmpStats) IcmpStats {
+	data, ok := quotedData(m)
+	if !ok {
+		ret.Valid = false
+		return ret
+	}
+
+	dst, id, seq, ok := parseQuotedEcho(recv.Proto, data)
+	if !ok {
+		return p.parseUDPError(recv, b, m, data, ret)
+	}
+
+	// Datagram sockets do not receive errors, but check id just in case
+	if p.protocol == "icmp" && id != p.id {
+		ret.Valid = false
+		return ret
+	}
+
+	ret.Seq = seq
+	ret.Error = &IcmpError{
+		Type:   icmpType(m),
+		Code:   m.Code,
+		Router: recv.Addr,
+		Dest:   dst,
+		MTU:    nextHopMTU(b, m),
+	}
+	return ret
+}
@@ -0,0 +1,118 @@
+package pinger
+
+import (
+	"net/netip"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// quote builds IP header of echo request, as it is quoted in ICMP error
+func quote(req *Packet, src netip.Addr) []byte {
+	if req.Proto == ProtocolIpv4 {
+		h := make([]byte, ipv4HeaderLen)
+		h[0] = 0x45
+		h[8] = 1
+		h[9] = ProtocolICMP
+		copy(h[12:16], src.AsSlice())
+		copy(h[16:20], req.Addr.AsSlice())
+		return append(h, req.Bytes[:echoHeaderLen]...)
+	}
+
+	h := make([]byte, ipv6HeaderLen)
+	h[0] = 0x60
+	h[6] = ProtocolIPv6ICMP
+	h[7] = 1
+	copy(h[8:24], src.AsSlice())
+	copy(h[24:40], req.Addr.AsSlice())
+	return append(h, req.Bytes[:echoHeaderLen]...)
+}
+
+func TestParseError(t *testing.T) {
+	p := NewPinger("ip", "icmp", 222)
+	router := netip.MustParseAddr("192.0.2.1")
+	router6 := netip.MustParseAddr("2001:db8::1")
+
+	tests := []struct {
+		dst    netip.Addr
+		router netip.Addr
+		msg    icmp.Message
+		typ    int
+	}{
+		{netip.MustParseAddr("198.51.100.1"), router,
+			icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 13}, 3},
+		{netip.MustParseAddr("198.51.100.2"), router,
+			icmp.Message{Type: ipv4.ICMPTypeTimeExceeded}, 11},
+		{netip.MustParseAddr("2001:db8:1::1"), router6,
+			icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: 1}, 1},
+	}
+
+	for _, test := range tests {
+		req, err := p.PrepareICMP(test.dst, testSeq)
+		if err != nil {
+			t.Fatalf("Icmp prepare %s", err)
+		}
+
+		data := quote(req, netip.MustParseAddr("203.0.113.1"))
+		if test.dst.Is6() {
+			data = quote(req, netip.MustParseAddr("2001:db8:2::1"))
+		}
+		if test.msg.Type == ipv4.ICMPTypeTimeExceeded {
+			test.msg.Body = &icmp.TimeExceeded{Data: data}
+		} else {
+			test.msg.Body = &icmp.DstUnreach{Data: data}
+		}
+
+		b, err := test.msg.Marshal(nil)
+		if err != nil {
+			t.Fatalf("Icmp marshal %s", err)
+		}
+
+		stats := p.ParsePacket(&Packet{Proto: req.Proto, Bytes: b, Len: len(b), Addr: test.router})
+		if !stats.Valid || stats.Error == nil {
+			t.Fatalf("Error message to %s was not parsed", test.dst)
+		}
+		if stats.Seq != testSeq || stats.Error.Dest != test.dst || stats.Error.Router != test.router ||
+			stats.Error.Type != test.typ || stats.Error.Code != test.msg.Code {
+			t.Errorf("Invalid error parsed: seq %d %+v", stats.Seq, *stats.Error)
+		}
+	}
+
+	// Error about echo request of other pinger
+	other := NewPinger("ip", "icmp", 333)
+	req, _ := other.PrepareICMP(tests[0].dst, testSeq)
+	msg := icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Body: &icmp.DstUnreach{Data: quote(req, router)}}
+	b, _ := msg.Marshal(nil)
+	if stats := p.ParsePacket(&Packet{Proto: ProtocolIpv4, Bytes: b, Len: len(b), Addr: router}); stats.Valid {
+		t.Errorf("Error of other pinger was accepted")
+	}
+}
+
+func TestParseUDPError(t *testing.T) {
+	p := NewPinger("ip", "icmp", 222)
+	host := netip.MustParseAddr("198.51.100.1")
+
+	// IPv4 header and UDP header of datagram from port 40000 to 33434
+	data := make([]byte, ipv4HeaderLen+8)
+	data[0] = 0x45
+	data[9] = ProtocolUDP
+	copy(data[16:20], host.AsSlice())
+	data[20], data[21], data[22], data[23] = 0x9c, 0x40, 0x82, 0x9a
+
+	msg := icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3, Body: &icmp.DstUnreach{Data: data}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		t.Fatalf("Icmp marshal %s", err)
+	}
+
+	stats := p.ParsePacket(&Packet{Proto: ProtocolIpv4, Bytes: b, Len: len(b), Addr: host})
+	if !stats.Valid || stats.Error == nil {
+		t.Fatal("Error about UDP datagram was not parsed")
+	}
+	e := stats.Error
+	if !e.UDP || e.SourcePort != 40000 || e.Port != 33434 || e.Dest != host || !e.PortUnreachable() {
+		t.Errorf("Invalid error parsed: %+v", *e)
+	}
+}
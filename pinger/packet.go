@@ -1,16 +1,42 @@
 package pinger
 
 import (
+	"fmt"
 	"net/netip"
 	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 type Packet struct {
 	Proto ProtocolVersion // protocol: 4=IPv4, 6=IPv6
 	Bytes []byte          // Marshaled package
 	Len   int             // length of package
-	TTL   int             // TTL of the packet (currently unused)
+	TTL   int             // TTL of received packet. When sending, positive TTL overrides socket TTL
+	TOS   int             // TOS (traffic class) of received packet, -1 if unknown. When sending, positive TOS overrides socket TOS
+	Seq   uint16          // Sequence number of prepared echo request
 	Addr  netip.Addr      // Dest address for sending package and Src address ro received
+	Port  uint16          // Local port of TCP or UDP socket, which received packet. It is 0 for ICMP
+
+	// When sending, set DF bit (IPv6: do not fragment locally) and ignore path MTU cache
+	DontFragment bool
+}
+
+// DSCP returns DSCP bits of packet TOS, -1 if unknown
+func (p *Packet) DSCP() int {
+	if p.TOS < 0 {
+		return -1
+	}
+	return p.TOS >> 2
+}
+
+// ECN returns ECN bits of packet TOS, -1 if unknown
+func (p *Packet) ECN() int {
+	if p.TOS < 0 {
+		return -1
+	}
+	return p.TOS & 0x03
 }
 
 type IcmpStats struct {
@@ -18,4 +44,44 @@ type IcmpStats struct {
 	RTT     time.Duration
 	Tracker int64
 	Seq     uint16
+	Error   *IcmpError // not nil, when packet is ICMP error about our echo request
+
+	// Not nil, when packet is ICMP timestamp reply. RTT has millisecond resolution then.
+	Timestamp *IcmpTimestamp
+
+	// Not nil, when packet is ICMP extended echo reply. Seq has 8 bits and RTT is unknown then.
+	Extended *ExtendedEcho
+
+	// Payload length of echo reply and whether payload differs from sent Pattern.
+	// Sent size is known to caller only, so it checks truncation itself.
+	Size      int
+	Corrupted bool
+
+	// Echo reply failed HMAC verification, see Pinger.Secret. Only Seq, Size and Tracker are set then.
+	Forged bool
+}
+
+// IcmpError is ICMP error message (e.g. destination unreachable), which quotes our echo request.
+// Error messages carry no payload of echo request, so RTT and Tracker are unknown.
+type IcmpError struct {
+	Type   int        // ICMP message type
+	Code   int        // ICMP message code
+	Router netip.Addr // sender of error message
+	Dest   netip.Addr // destination of original echo request
+	MTU    int        // next hop MTU of IPv4 fragmentation needed or IPv6 packet too big, 0 if unknown
+
+	// Error is about UDP datagram and not echo request. Datagram is identified by its ports.
+	UDP        bool
+	SourcePort uint16
+	Port       uint16
+}
+
+// PortUnreachable returns whether error is IPv4 or IPv6 port unreachable message
+func (e *IcmpError) PortUnreachable() bool {
+	return (e.Dest.Is4() && e.Type == int(ipv4.ICMPTypeDestinationUnreachable) && e.Code == codePortUnreachable) ||
+		(e.Dest.Is6() && e.Type == int(ipv6.ICMPTypeDestinationUnreachable) && e.Code == codePortUnreachable6)
+}
+
+func (e *IcmpError) Error() string {
+	return fmt.Sprintf("ICMP error type %d code %d from %s", e.Type, e.Code, e.Router)
 }
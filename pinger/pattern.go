@@ -0,0 +1,87 @@
+package pinger
+
+// PatternKind tells how echo request payload is filled after timestamp and tracker
+type PatternKind int
+
+const (
+	PatternConstant  PatternKind = iota // every byte is Pattern.Byte
+	PatternIncrement                    // bytes count up from Pattern.Byte, wrapping after 255
+	PatternRandom                       // pseudo random bytes, the same for the same Pattern.Seed
+)
+
+// Pattern describes payload filler. Echo replies are verified against it,
+// so payload corrupted on the way is detected.
+type Pattern struct {
+	Kind PatternKind
+	Byte byte  // constant byte or the first byte of incrementing pattern
+	Seed int64 // seed of random pattern
+}
+
+// DefaultPattern fills payload with 0x01 bytes
+var DefaultPattern = Pattern{Kind: PatternConstant, Byte: 1}
+
+// Valid returns whether pattern kind is known
+func (pt Pattern) Valid() bool {
+	return pt.Kind >= PatternConstant && pt.Kind <= PatternRandom
+}
+
+// fill writes pattern to b
+func (pt Pattern) fill(b []byte) {
+	g := pt.generator()
+	for i := range b {
+		b[i] = g.next()
+	}
+}
+
+// match returns whether b is filled with pattern
+func (pt Pattern) match(b []byte) bool {
+	g := pt.generator()
+	for i := range b {
+		if b[i] != g.next() {
+			return false
+		}
+	}
+	return true
+}
+
+func (pt Pattern) generator() patternGenerator {
+	return patternGenerator{kind: pt.Kind, value: pt.Byte, state: uint64(pt.Seed)}
+}
+
+// patternGenerator produces pattern bytes one by one
+type patternGenerator struct {
+	kind  PatternKind
+	value byte
+	state uint64 // splitmix64 state of random pattern
+	bits  uint64 // random bytes not yet used
+	left  int    // count of bytes left in bits
+}
+
+func (g *patternGenerator) next() byte {
+	switch g.kind {
+	case PatternIncrement:
+		b := g.value
+		g.value++
+		return b
+	case PatternRandom:
+		if g.left == 0 {
+			g.bits = g.splitmix()
+			g.left = 8
+		}
+		b := byte(g.bits)
+		g.bits >>= 8
+		g.left--
+		return b
+	}
+	return g.value
+}
+
+// splitmix returns the next splitmix64 number. It is fast and
+// does not need allocations unlike math/rand sources.
+func (g *patternGenerator) splitmix() uint64 {
+	g.state += 0x9e3779b97f4a7c15
+	z := g.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,71 @@
+package pinger
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestPattern(t *testing.T) {
+	patterns := []Pattern{
+		DefaultPattern,
+		{Kind: PatternIncrement, Byte: 250},
+		{Kind: PatternRandom, Seed: 42},
+	}
+
+	for _, pt := range patterns {
+		b := make([]byte, 100)
+		pt.fill(b)
+		if !pt.match(b) || !pt.match(b[:10]) {
+			t.Errorf("Pattern %+v does not match itself", pt)
+		}
+		b[50] ^= 0x10
+		if pt.match(b) {
+			t.Errorf("Pattern %+v matched corrupted payload", pt)
+		}
+	}
+
+	inc := make([]byte, 8)
+	Pattern{Kind: PatternIncrement, Byte: 254}.fill(inc)
+	if !bytes.Equal(inc[:4], []byte{254, 255, 0, 1}) {
+		t.Errorf("Invalid incrementing pattern %v", inc)
+	}
+
+	r1, r2 := make([]byte, 32), make([]byte, 32)
+	Pattern{Kind: PatternRandom, Seed: 1}.fill(r1)
+	Pattern{Kind: PatternRandom, Seed: 2}.fill(r2)
+	if bytes.Equal(r1, r2) {
+		t.Error("Different seeds give the same random pattern")
+	}
+
+	if (Pattern{Kind: PatternRandom + 1}).Valid() {
+		t.Error("Unknown pattern kind is valid")
+	}
+}
+
+func TestParseCorrupted(t *testing.T) {
+	p := NewPinger("ip", "udp", 1)
+	p.Pattern = Pattern{Kind: PatternRandom, Seed: 7}
+
+	pkt, err := p.PrepareICMPSize(netip.MustParseAddr("127.0.0.1"), testSeq, 200)
+	if err != nil {
+		t.Fatalf("Icmp prepare %s", err)
+	}
+	// Echo reply is type 0, checksum is not verified on receive
+	pkt.Bytes[0] = 0
+
+	stats := p.ParsePacket(pkt)
+	if !stats.Valid || stats.Corrupted || stats.Size != 200 {
+		t.Fatalf("Invalid intact reply stats %+v", stats)
+	}
+
+	pkt.Bytes[150] ^= 0xff
+	if stats = p.ParsePacket(pkt); !stats.Valid || !stats.Corrupted {
+		t.Errorf("Corruption not detected %+v", stats)
+	}
+
+	pkt.Len = 100
+	if stats = p.ParsePacket(pkt); !stats.Valid || stats.Corrupted || stats.Size != 100-8 {
+		t.Errorf("Invalid truncated reply stats %+v", stats)
+	}
+}
@@ -10,7 +10,8 @@ import (
 // NewPinger returns a new Pinger instance
 func NewPinger(network, protocol string, id uint16) *Pinger {
 	p := &Pinger{
-		Size: timeSliceLength,
+		Size:    timeSliceLength,
+		Pattern: DefaultPattern,
 
 		id:       id,
 		network:  network,
@@ -23,27 +24,40 @@ func NewPinger(network, protocol string, id uint16) *Pinger {
 
 // Pinger represents a packet sender.
 type Pinger struct {
-	// Size of packet being sent
+	// Size of packet payload being sent. Payload is never smaller than MinSize.
 	Size int
 
+	// Pattern fills payload after timestamp and tracker
+	Pattern Pattern
+
 	// Tracker: Used to uniquely identify packet when non-priviledged
 	Tracker int64
 
+	// Secret enables HMAC authentication of echo payloads, see MinPayload.
+	// Replies, which fail verification, are reported as Forged. Nil disables it.
+	Secret []byte
+
 	id uint16
 	// network is one of "ip", "ip4", or "ip6".
 	network string
 	// protocol is "icmp" or "udp".
 	protocol string
 
-	//conn4 is ipv4 icmp PacketConn
-	conn4 *icmp.PacketConn
+	//conn4 is ipv4 icmp connection
+	conn4 *Conn
 
-	//conn6 is ipv6 icmp PacketConn
-	conn6 *icmp.PacketConn
+	//conn6 is ipv6 icmp connection
+	conn6 *Conn
 }
 
 // SetConns setups IPv4 and IPv6 connections to pinger
 func (p *Pinger) SetConns(c4 *icmp.PacketConn, c6 *icmp.PacketConn) {
+	p.conn4 = wrapConn(c4, ProtocolIpv4, p.Privileged())
+	p.conn6 = wrapConn(c6, ProtocolIpv6, p.Privileged())
+}
+
+// SetSockets setups IPv4 and IPv6 connections opened with ListenConfig
+func (p *Pinger) SetSockets(c4 *Conn, c6 *Conn) {
 	p.conn4 = c4
 	p.conn6 = c6
 }
@@ -3,6 +3,7 @@ package pinger
 import (
 	"net"
 	"net/netip"
+	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
@@ -10,6 +11,14 @@ import (
 	"golang.org/x/net/ipv6"
 )
 
+// recvBuffers are large enough for any packet, so replies are never truncated locally
+var recvBuffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, maxPacketSize)
+		return &b
+	},
+}
+
 func (p *Pinger) RecvICMP(proto ProtocolVersion) IcmpStats {
 	pkt, err := p.RecvPacket(proto)
 	if err != nil {
@@ -22,30 +31,23 @@ func (p *Pinger) RecvICMP(proto ProtocolVersion) IcmpStats {
 }
 
 func (p *Pinger) RecvPacket(proto ProtocolVersion) (*Packet, error) {
-	var n, ttl int
+	var n, ttl, tos int
 	var err error
 	var src net.Addr
-	bytes := make([]byte, 512)
+	buf := recvBuffers.Get().(*[]byte)
+	defer recvBuffers.Put(buf)
+	bytes := *buf
 
 	if proto == ProtocolIpv4 {
 		if p.conn4 == nil {
 			return nil, ErrInvalidConn
 		}
-		var cm *ipv4.ControlMessage
-		n, cm, src, err = p.conn4.IPv4PacketConn().ReadFrom(bytes)
-		if cm != nil {
-			ttl = cm.TTL
-		}
+		n, ttl, tos, src, err = p.conn4.readFrom(bytes)
 	} else {
-		if p.conn4 == nil {
+		if p.conn6 == nil {
 			return nil, ErrInvalidConn
 		}
-
-		var cm *ipv6.ControlMessage
-		n, cm, src, err = p.conn6.IPv6PacketConn().ReadFrom(bytes)
-		if cm != nil {
-			ttl = cm.HopLimit
-		}
+		n, ttl, tos, src, err = p.conn6.readFrom(bytes)
 	}
 
 	// Error reeading from connection. Can happen one of 2:
@@ -73,7 +75,9 @@ func (p *Pinger) RecvPacket(proto ProtocolVersion) (*Packet, error) {
 		return nil, ErrInvalidAddr
 	}
 
-	return &Packet{Bytes: bytes, Len: n, TTL: ttl, Proto: proto, Addr: addr}, nil
+	// Buffer is reused, packet gets a copy of its bytes
+	bytes = append([]byte(nil), bytes[:n]...)
+	return &Packet{Bytes: bytes, Len: n, TTL: ttl, TOS: tos, Proto: proto, Addr: addr}, nil
 }
 
 func (p *Pinger) ParsePacket(recv *Packet) IcmpStats {
@@ -81,12 +85,17 @@ func (p *Pinger) ParsePacket(recv *Packet) IcmpStats {
 		Valid: true,
 	}
 
+	bytes := recv.Bytes
+	if recv.Len > 0 && recv.Len < len(bytes) {
+		bytes = bytes[:recv.Len]
+	}
+
 	var m *icmp.Message
 	var err error
 	if recv.Proto == ProtocolIpv4 {
-		m, err = icmp.ParseMessage(ProtocolICMP, recv.Bytes)
+		m, err = icmp.ParseMessage(ProtocolICMP, bytes)
 	} else {
-		m, err = icmp.ParseMessage(ProtocolIPv6ICMP, recv.Bytes)
+		m, err = icmp.ParseMessage(ProtocolIPv6ICMP, bytes)
 	}
 
 	if err != nil {
@@ -94,7 +103,18 @@ func (p *Pinger) ParsePacket(recv *Packet) IcmpStats {
 		return ret
 	}
 
-	if m.Type != ipv4.ICMPTypeEchoReply && m.Type != ipv6.ICMPTypeEchoReply {
+	switch m.Type {
+	case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
+	case ipv4.ICMPTypeDestinationUnreachable, ipv4.ICMPTypeTimeExceeded, ipv4.ICMPTypeParameterProblem,
+		ipv6.ICMPTypeDestinationUnreachable, ipv6.ICMPTypePacketTooBig, ipv6.ICMPTypeTimeExceeded,
+		ipv6.ICMPTypeParameterProblem:
+		// Error about echo request, it quotes request header
+		return p.parseError(recv, bytes, m, ret)
+	case ipv4.ICMPTypeTimestampReply:
+		return p.parseTimestamp(m, ret)
+	case ipv4.ICMPTypeExtendedEchoReply, ipv6.ICMPTypeExtendedEchoReply:
+		return p.parseExtendedEcho(m, ret)
+	default:
 		// Not an echo reply, ignore it
 		ret.Valid = false
 		return ret
@@ -121,7 +141,16 @@ func (p *Pinger) ParsePacket(recv *Packet) IcmpStats {
 	}
 
 	ret.Seq = uint16(pkt.Seq)
+	ret.Size = len(pkt.Data)
 	ret.Tracker = bytesToInt(pkt.Data[timeSliceLength:])
+	if len(p.Secret) > 0 && !p.authentic(ret.Seq, pkt.Data, recv.Addr) {
+		// Timestamp can't be trusted either
+		ret.Forged = true
+		return ret
+	}
+	if len(pkt.Data) >= p.MinPayload() {
+		ret.Corrupted = !p.Pattern.match(pkt.Data[p.MinPayload():])
+	}
 	timestamp := bytesToTime(pkt.Data[:timeSliceLength])
 	ret.RTT = time.Since(timestamp)
 
@@ -0,0 +1,52 @@
+package pinger
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// enableRecvTOS asks datagram socket for TOS of received packets.
+// golang.org/x/net/ipv4 can't parse it, so IPv4 control messages are parsed by readDatagram4.
+func enableRecvTOS(pc *net.UDPConn) error {
+	raw, err := pc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+	err = raw.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_RECVTOS, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+// readDatagram4 reads ICMP message from IPv4 datagram socket with TTL and TOS control messages
+func readDatagram4(pc *net.UDPConn, b []byte) (n, ttl, tos int, src net.Addr, err error) {
+	oob := make([]byte, 2*syscall.CmsgSpace(4))
+	n, oobn, _, addr, err := pc.ReadMsgUDP(b, oob)
+	if err != nil {
+		return 0, 0, -1, nil, err
+	}
+
+	tos = -1
+	msgs, _ := syscall.ParseSocketControlMessage(oob[:oobn])
+	for _, m := range msgs {
+		if m.Header.Level != syscall.IPPROTO_IP || len(m.Data) == 0 {
+			continue
+		}
+		switch m.Header.Type {
+		case syscall.IP_TTL:
+			// int in host byte order
+			if len(m.Data) >= 4 {
+				ttl = int(*(*int32)(unsafe.Pointer(&m.Data[0])))
+			}
+		case syscall.IP_TOS:
+			tos = int(m.Data[0])
+		}
+	}
+	return n, ttl, tos, addr, nil
+}
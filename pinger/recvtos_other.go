@@ -0,0 +1,15 @@
+//go:build !linux
+
+package pinger
+
+import "net"
+
+// enableRecvTOS is not supported, TOS of IPv4 datagram replies is unknown
+func enableRecvTOS(pc *net.UDPConn) error {
+	return ErrPacketOptions
+}
+
+// readDatagram4 is never called, as enableRecvTOS fails
+func readDatagram4(pc *net.UDPConn, b []byte) (n, ttl, tos int, src net.Addr, err error) {
+	return 0, 0, -1, nil, ErrPacketOptions
+}
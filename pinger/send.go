@@ -1,7 +1,6 @@
 package pinger
 
 import (
-	"bytes"
 	"net"
 	"net/netip"
 	"syscall"
@@ -23,14 +22,25 @@ func (p *Pinger) SendICMP(addr netip.Addr, sequence uint16) error {
 }
 
 func (p *Pinger) PrepareICMP(addr netip.Addr, seq uint16) (*Packet, error) {
+	return p.PrepareICMPSize(addr, seq, p.Size)
+}
+
+// PrepareICMPSize prepares echo request with payload of size bytes instead of Size
+func (p *Pinger) PrepareICMPSize(addr netip.Addr, seq uint16, size int) (*Packet, error) {
 	var err error
 	pkt := Packet{
 		Addr: addr,
+		Seq:  seq,
 	}
 
 	t := append(timeToBytes(time.Now()), intToBytes(p.Tracker)...)
-	if remainSize := p.Size - timeSliceLength - trackerLength; remainSize > 0 {
-		t = append(t, bytes.Repeat([]byte{1}, remainSize)...)
+	if len(p.Secret) > 0 {
+		t = append(t, p.mac(seq, t, addr)...)
+	}
+	if remainSize := size - len(t); remainSize > 0 {
+		filler := make([]byte, remainSize)
+		p.Pattern.fill(filler)
+		t = append(t, filler...)
 	}
 
 	body := &icmp.Echo{
@@ -63,13 +73,6 @@ func (p *Pinger) PrepareICMP(addr netip.Addr, seq uint16) (*Packet, error) {
 func (p *Pinger) SendPacket(pkt *Packet) error {
 	var err error
 
-	var dst net.Addr
-	if p.protocol == "udp" {
-		dst = &net.UDPAddr{IP: pkt.Addr.AsSlice(), Zone: pkt.Addr.Zone()}
-	} else {
-		dst = &net.IPAddr{IP: pkt.Addr.AsSlice()}
-	}
-
 	// Some retries in case of ENOBUFS may occure
 	// Do not retry infinitely
 	for tries := 6; tries > 0; tries-- {
@@ -77,12 +80,12 @@ func (p *Pinger) SendPacket(pkt *Packet) error {
 			if p.conn4 == nil {
 				return ErrInvalidConn
 			}
-			_, err = p.conn4.WriteTo(pkt.Bytes, dst)
+			_, err = p.conn4.writeTo(pkt)
 		} else {
 			if p.conn6 == nil {
 				return ErrInvalidConn
 			}
-			_, err = p.conn6.WriteTo(pkt.Bytes, dst)
+			_, err = p.conn6.writeTo(pkt)
 		}
 
 		if err != nil {
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package pinger
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+)
+
+// listenDatagram is not supported, only privileged raw sockets can be used
+func listenDatagram(proto ProtocolVersion, source netip.Addr) (net.PacketConn, error) {
+	return nil, errors.New("unprivileged ICMP sockets are not supported on this platform")
+}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package pinger
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// IP_STRIPHDR makes darwin strip IPv4 header from datagram ICMP socket reads
+const sysIPStripHdr = 0x17
+
+// listenDatagram opens "unprivileged" datagram ICMP socket bound to source.
+// It is the same as icmp.ListenPacket("udp4"/"udp6"), but returns *net.UDPConn,
+// so that socket options can be set.
+func listenDatagram(proto ProtocolVersion, source netip.Addr) (net.PacketConn, error) {
+	family, icmpProto := syscall.AF_INET, ProtocolICMP
+	if proto == ProtocolIpv6 {
+		family, icmpProto = syscall.AF_INET6, ProtocolIPv6ICMP
+	}
+
+	s, err := syscall.Socket(family, syscall.SOCK_DGRAM, icmpProto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	if (runtime.GOOS == "darwin" || runtime.GOOS == "ios") && family == syscall.AF_INET {
+		if err := syscall.SetsockoptInt(s, syscall.IPPROTO_IP, sysIPStripHdr, 1); err != nil {
+			syscall.Close(s)
+			return nil, os.NewSyscallError("setsockopt", err)
+		}
+	}
+
+	if err := syscall.Bind(s, sockaddr(proto, source)); err != nil {
+		syscall.Close(s)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	f := os.NewFile(uintptr(s), "datagram-oriented icmp")
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
+
+// sockaddr converts source to socket address, invalid source means any address
+func sockaddr(proto ProtocolVersion, source netip.Addr) syscall.Sockaddr {
+	if proto == ProtocolIpv4 {
+		sa := &syscall.SockaddrInet4{}
+		if source.IsValid() {
+			sa.Addr = source.As4()
+		}
+		return sa
+	}
+
+	sa := &syscall.SockaddrInet6{}
+	if source.IsValid() {
+		sa.Addr = source.As16()
+		if zone := source.Zone(); zone != "" {
+			if ifi, err := net.InterfaceByName(zone); err == nil {
+				sa.ZoneId = uint32(ifi.Index)
+			}
+		}
+	}
+	return sa
+}
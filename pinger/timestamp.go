@@ -0,0 +1,127 @@
+package pinger
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	timestampBodyLen = 16 // id, seq and 3 timestamps
+	msPerDay         = 24 * 60 * 60 * 1000
+
+	// nonStandardTime is set in timestamp, which is not milliseconds since midnight UT
+	nonStandardTime = 1 << 31
+)
+
+// IcmpTimestamp holds timestamps of ICMP timestamp reply in milliseconds since midnight UT
+type IcmpTimestamp struct {
+	Originate uint32 // when request was sent, by local clock
+	Receive   uint32 // when request was received, by remote clock
+	Transmit  uint32 // when reply was sent, by remote clock
+	Arrival   uint32 // when reply was received, by local clock
+}
+
+// Standard returns whether remote timestamps are milliseconds since midnight UT.
+// Otherwise remote clock is unknown and offset can't be estimated.
+func (t *IcmpTimestamp) Standard() bool {
+	return t.Receive&nonStandardTime == 0 && t.Transmit&nonStandardTime == 0
+}
+
+// RTT returns round trip time without time spent by remote host
+func (t *IcmpTimestamp) RTT() time.Duration {
+	rtt := msDiff(t.Arrival, t.Originate)
+	if t.Standard() {
+		rtt -= msDiff(t.Transmit, t.Receive)
+	}
+	if rtt < 0 {
+		return 0
+	}
+	return rtt
+}
+
+// Offset returns estimated offset of remote clock from local clock, assuming symmetric path
+func (t *IcmpTimestamp) Offset() time.Duration {
+	return (msDiff(t.Receive, t.Originate) + msDiff(t.Transmit, t.Arrival)) / 2
+}
+
+// Forward returns one-way delay of request measured by both clocks. It includes clock offset.
+func (t *IcmpTimestamp) Forward() time.Duration {
+	return msDiff(t.Receive, t.Originate)
+}
+
+// Return returns one-way delay of reply measured by both clocks. It includes clock offset with opposite sign.
+func (t *IcmpTimestamp) Return() time.Duration {
+	return msDiff(t.Arrival, t.Transmit)
+}
+
+// msDiff returns a-b of timestamps in milliseconds since midnight. Result wraps around midnight.
+func msDiff(a, b uint32) time.Duration {
+	d := (int64(a) - int64(b)) % msPerDay
+	if d >= msPerDay/2 {
+		d -= msPerDay
+	} else if d < -msPerDay/2 {
+		d += msPerDay
+	}
+	return time.Duration(d) * time.Millisecond
+}
+
+// msSinceMidnight returns timestamp of t as ICMP timestamp messages use it
+func msSinceMidnight(t time.Time) uint32 {
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return uint32(t.Sub(midnight) / time.Millisecond)
+}
+
+// PrepareTimestamp prepares ICMP timestamp request. It is IPv4 only message.
+func (p *Pinger) PrepareTimestamp(addr netip.Addr, seq uint16) (*Packet, error) {
+	if !addr.Is4() {
+		return nil, fmt.Errorf("%w: timestamp request is IPv4 only: %s", ErrInvalidAddr, addr)
+	}
+
+	body := make([]byte, timestampBodyLen)
+	binary.BigEndian.PutUint16(body[0:], p.id)
+	binary.BigEndian.PutUint16(body[2:], seq)
+	binary.BigEndian.PutUint32(body[4:], msSinceMidnight(time.Now()))
+
+	msg := &icmp.Message{
+		Type: ipv4.ICMPTypeTimestamp,
+		Body: &icmp.RawBody{Data: body},
+	}
+
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Packet{Proto: ProtocolIpv4, Bytes: b, Len: len(b), Seq: seq, Addr: addr}, nil
+}
+
+// parseTimestamp parses ICMP timestamp reply
+func (p *Pinger) parseTimestamp(m *icmp.Message, ret IcmpStats) IcmpStats {
+	body, ok := m.Body.(*icmp.RawBody)
+	// Datagram sockets can't send timestamp requests, so reply is not ours
+	if !ok || len(body.Data) < timestampBodyLen || !p.Privileged() {
+		ret.Valid = false
+		return ret
+	}
+
+	if binary.BigEndian.Uint16(body.Data[0:]) != p.id {
+		ret.Valid = false
+		return ret
+	}
+
+	ts := &IcmpTimestamp{
+		Originate: binary.BigEndian.Uint32(body.Data[4:]),
+		Receive:   binary.BigEndian.Uint32(body.Data[8:]),
+		Transmit:  binary.BigEndian.Uint32(body.Data[12:]),
+		Arrival:   msSinceMidnight(time.Now()),
+	}
+	ret.Seq = binary.BigEndian.Uint16(body.Data[2:])
+	ret.Timestamp = ts
+	ret.RTT = ts.RTT()
+	return ret
+}
@@ -0,0 +1,76 @@
+package pinger
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestMsDiff(t *testing.T) {
+	checks := []struct {
+		a, b uint32
+		diff time.Duration
+	}{
+		{1000, 400, 600 * time.Millisecond},
+		{400, 1000, -600 * time.Millisecond},
+		// Around midnight
+		{100, msPerDay - 100, 200 * time.Millisecond},
+		{msPerDay - 100, 100, -200 * time.Millisecond},
+	}
+	for _, c := range checks {
+		if diff := msDiff(c.a, c.b); diff != c.diff {
+			t.Errorf("%d - %d: expected %s, got %s", c.a, c.b, c.diff, diff)
+		}
+	}
+}
+
+func TestIcmpTimestamp(t *testing.T) {
+	// Remote clock is 1s ahead, request takes 10ms and reply 20ms, remote processing 5ms
+	ts := IcmpTimestamp{Originate: 5000, Receive: 6010, Transmit: 6015, Arrival: 5035}
+	if ts.RTT() != 30*time.Millisecond {
+		t.Errorf("Invalid RTT %s", ts.RTT())
+	}
+	if ts.Offset() != 995*time.Millisecond {
+		t.Errorf("Invalid offset %s", ts.Offset())
+	}
+	if ts.Forward() != 1010*time.Millisecond || ts.Return() != -980*time.Millisecond {
+		t.Errorf("Invalid one-way delays %s, %s", ts.Forward(), ts.Return())
+	}
+
+	ts.Receive |= nonStandardTime
+	if ts.Standard() {
+		t.Error("Non standard timestamp is standard")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	p := NewPinger("ip4", "icmp", 77)
+
+	pkt, err := p.PrepareTimestamp(netip.MustParseAddr("127.0.0.1"), testSeq)
+	if err != nil {
+		t.Fatalf("Timestamp prepare %s", err)
+	}
+	if _, err = p.PrepareTimestamp(netip.MustParseAddr("::1"), testSeq); err == nil {
+		t.Error("IPv6 timestamp request prepared")
+	}
+
+	// Turn request into reply, checksum is not verified on receive
+	pkt.Bytes[0] = 14
+	originate := binary.BigEndian.Uint32(pkt.Bytes[8:])
+	binary.BigEndian.PutUint32(pkt.Bytes[12:], originate+1)
+	binary.BigEndian.PutUint32(pkt.Bytes[16:], originate+2)
+
+	stats := p.ParsePacket(pkt)
+	if !stats.Valid || stats.Seq != testSeq || stats.Timestamp == nil {
+		t.Fatalf("Invalid timestamp reply stats %+v", stats)
+	}
+	if stats.Timestamp.Receive != originate+1 || stats.Timestamp.Transmit != originate+2 {
+		t.Errorf("Invalid timestamps %+v", stats.Timestamp)
+	}
+
+	p.SetPrivileged(false)
+	if p.ParsePacket(pkt).Valid {
+		t.Error("Timestamp reply accepted by unprivileged pinger")
+	}
+}
@@ -0,0 +1,200 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// DefaultMaxMTU is the largest path MTU probed by default
+const DefaultMaxMTU = 1500
+
+// Headers, which are not part of echo request payload
+const (
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	icmpHeaderLen = 8
+)
+
+// PMTUConfig holds path MTU discovery settings
+type PMTUConfig struct {
+	// MaxMTU is the largest path MTU probed. Default is DefaultMaxMTU.
+	MaxMTU int
+
+	// Timeout to wait for reply to every probe. Default is MultiPing Timeout.
+	Timeout time.Duration
+}
+
+// pmtuSearch is a binary search of path MTU to a single host
+type pmtuSearch struct {
+	good  int  // the largest answered MTU, 0 if none
+	bad   int  // the smallest not answered MTU
+	floor int  // the smallest MTU, which fits echo request
+	hint  int  // MTU reported by router, 0 if unknown
+	step  int  // count of probes sent
+	dead  bool // host does not answer even the smallest probe
+
+	floorTried bool // the smallest MTU was probed
+}
+
+// PathMTU finds the largest packet size, which reaches every target and comes back.
+// Echo requests are sent with DF bit set and their size is binary searched for all targets at once.
+// ICMP fragmentation needed (IPv6 packet too big) messages speed search up, but they are received
+// only in privileged mode. Without them search relies on timeouts.
+// Returned path MTU is IP packet size, 0 for hosts which did not answer at all.
+// On platforms, where DF bit can't be set, pinger.ErrPacketOptions is returned.
+func (mp *MultiPing) PathMTU(ctx context.Context, targets []netip.Addr, cfg PMTUConfig) (map[netip.Addr]int, error) {
+	if !pinger.DontFragmentSupported {
+		return nil, fmt.Errorf("%w: DF bit can't be set", pinger.ErrPacketOptions)
+	}
+	if cfg.MaxMTU == 0 {
+		cfg.MaxMTU = DefaultMaxMTU
+	}
+	if cfg.MaxMTU < ipv6HeaderLen+icmpHeaderLen+pinger.MinSize || cfg.MaxMTU > 65535 {
+		return nil, fmt.Errorf("%w: max MTU %d is out of range [%d, 65535]",
+			ErrInvalidOption, cfg.MaxMTU, ipv6HeaderLen+icmpHeaderLen+pinger.MinSize)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = mp.Timeout
+	}
+
+	searches := make(map[netip.Addr]*pmtuSearch, len(targets))
+	for _, addr := range targets {
+		s := &pmtuSearch{bad: cfg.MaxMTU + 1, floor: ipv4HeaderLen + icmpHeaderLen + mp.pinger.MinPayload()}
+		if addr.Is6() {
+			s.floor = ipv6HeaderLen + icmpHeaderLen + mp.pinger.MinPayload()
+		}
+		searches[addr] = s
+	}
+
+	var optErr error // DF bit could not be set
+	for {
+		round := RoundConfig{
+			Timeout:     cfg.Timeout,
+			Count:       1,
+			FinishEarly: true,
+			Targets:     make(map[netip.Addr]ProbeOptions),
+		}
+
+		var hosts []netip.Addr
+		for addr, s := range searches {
+			if mtu := s.next(); mtu > 0 {
+				hosts = append(hosts, addr)
+				round.Targets[addr] = ProbeOptions{Size: mtu - headerLen(addr), DontFragment: true}
+			}
+		}
+		if len(hosts) == 0 {
+			break
+		}
+
+		for res := range mp.StreamConfig(ctx, hosts, round) {
+			s := searches[res.Addr]
+			mtu := round.Targets[res.Addr].Size + headerLen(res.Addr)
+			switch res.Kind {
+			case ProbeReply, ProbeCorrupted:
+				// Request of this size got through
+				s.good = mtu
+			case ProbeError:
+				s.fail(mtu)
+				var icmpErr *pinger.IcmpError
+				if errors.As(res.Err, &icmpErr) && icmpErr.MTU > 0 && icmpErr.MTU < mtu {
+					s.limit(icmpErr.MTU)
+				}
+			case ProbeSendError:
+				switch {
+				case errors.Is(res.Err, syscall.EMSGSIZE):
+					// Larger than local interface MTU
+					s.fail(mtu)
+				case errors.Is(res.Err, pinger.ErrPacketOptions):
+					// Not about host, probes can't be sent at all
+					optErr = res.Err
+				default:
+					s.dead = true
+				}
+			case ProbeTimeout, ProbeTruncated:
+				s.fail(mtu)
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if optErr != nil {
+			return nil, optErr
+		}
+	}
+
+	result := make(map[netip.Addr]int, len(searches))
+	for addr, s := range searches {
+		result[addr] = s.good
+	}
+	return result, nil
+}
+
+// headerLen returns length of IP and ICMP headers of echo request to addr
+func headerLen(addr netip.Addr) int {
+	if addr.Is4() {
+		return ipv4HeaderLen + icmpHeaderLen
+	}
+	return ipv6HeaderLen + icmpHeaderLen
+}
+
+// next returns MTU to probe, 0 when search is over.
+// The largest MTU is probed first, as it usually works. MTU reported by router is tried next.
+// If nothing is answered, the smallest MTU is probed to check that host is alive at all.
+// Then search is binary.
+func (s *pmtuSearch) next() int {
+	if s.dead || s.bad <= s.floor {
+		return 0
+	}
+	if s.step == 0 {
+		return s.probe(s.bad - 1)
+	}
+
+	lo := s.good
+	if lo == 0 {
+		lo = s.floor - 1
+	}
+	if s.bad-lo <= 1 {
+		return 0
+	}
+
+	if s.hint > lo && s.hint < s.bad {
+		hint := s.hint
+		s.hint = 0
+		return s.probe(hint)
+	}
+	if s.good == 0 && !s.floorTried {
+		s.floorTried = true
+		return s.probe(s.floor)
+	}
+	return s.probe((lo + s.bad) / 2)
+}
+
+func (s *pmtuSearch) probe(mtu int) int {
+	s.step++
+	return mtu
+}
+
+// limit registers MTU reported by router. Larger packets can't pass it.
+func (s *pmtuSearch) limit(mtu int) {
+	s.hint = mtu
+	if mtu+1 < s.bad {
+		s.bad = mtu + 1
+	}
+}
+
+// fail registers MTU, which was not answered
+func (s *pmtuSearch) fail(mtu int) {
+	if mtu < s.bad {
+		s.bad = mtu
+	}
+	if mtu == s.floor && s.good == 0 {
+		s.dead = true
+	}
+}
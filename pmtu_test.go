@@ -0,0 +1,60 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+func TestPMTUSearch(t *testing.T) {
+	// Simulated path: answers up to pmtu, router may report its MTU
+	for _, pmtu := range []int{0, 44, 576, 1280, 1400, 1499, 1500} {
+		for _, hints := range []bool{false, true} {
+			s := &pmtuSearch{bad: DefaultMaxMTU + 1, floor: 44}
+			for mtu := s.next(); mtu > 0; mtu = s.next() {
+				if s.step > 20 {
+					t.Fatalf("PMTU %d: search does not end", pmtu)
+				}
+				if mtu <= pmtu {
+					s.good = mtu
+					continue
+				}
+				s.fail(mtu)
+				if hints && pmtu > 0 {
+					s.limit(pmtu)
+				}
+			}
+			if s.good != pmtu {
+				t.Errorf("PMTU %d hints %v: found %d", pmtu, hints, s.good)
+			}
+			if hints && pmtu > 0 && s.step > 2 {
+				t.Errorf("PMTU %d: router MTU was not used, %d steps", pmtu, s.step)
+			}
+		}
+	}
+}
+
+func TestPathMTU(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+
+	local := netip.MustParseAddr("127.0.0.1")
+	result, err := mp.PathMTU(context.Background(), []netip.Addr{local}, PMTUConfig{MaxMTU: 9000})
+	if !pinger.DontFragmentSupported {
+		if !errors.Is(err, pinger.ErrPacketOptions) {
+			t.Errorf("Expected packet options error without DF support, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("PathMTU failed: %s", err)
+	}
+	if result[local] != 9000 {
+		t.Errorf("Invalid localhost path MTU %d", result[local])
+	}
+}
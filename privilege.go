@@ -0,0 +1,95 @@
+package multiping
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// Mode is a kind of ICMP sockets used for pinging
+type Mode int
+
+const (
+	ModeNone         Mode = iota // ICMP sockets can't be opened
+	ModePrivileged               // raw ICMP sockets
+	ModeUnprivileged             // "unprivileged" datagram ICMP sockets
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeNone:
+		return "none"
+	case ModePrivileged:
+		return "privileged"
+	case ModeUnprivileged:
+		return "unprivileged"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Detection is a result of ICMP socket mode detection
+type Detection struct {
+	// Mode chosen. Privileged mode is preferred, when both are available.
+	Mode Mode
+
+	// Explicit is true, when mode was set with WithPrivileged and not detected
+	Explicit bool
+
+	// Reasons why modes were rejected, nil if mode is usable or was not checked
+	Privileged   error
+	Unprivileged error
+}
+
+func (d Detection) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "mode %s", d.Mode)
+	if d.Explicit {
+		b.WriteString(" (explicit)")
+	}
+	if d.Privileged != nil {
+		fmt.Fprintf(&b, "; privileged rejected: %v", d.Privileged)
+	}
+	if d.Unprivileged != nil {
+		fmt.Fprintf(&b, "; unprivileged rejected: %v", d.Unprivileged)
+	}
+	return b.String()
+}
+
+// DetectMode checks, which ICMP sockets the process may use.
+// On linux effective UID, CAP_NET_RAW and ping_group_range are checked,
+// then candidate mode is confirmed by opening a socket.
+func DetectMode() Detection {
+	var d Detection
+
+	d.Privileged = checkPrivileged()
+	if d.Privileged == nil {
+		d.Privileged = tryListen(true)
+	}
+	if d.Privileged == nil {
+		d.Mode = ModePrivileged
+		return d
+	}
+
+	d.Unprivileged = checkUnprivileged()
+	if d.Unprivileged == nil {
+		d.Unprivileged = tryListen(false)
+	}
+	if d.Unprivileged == nil {
+		d.Mode = ModeUnprivileged
+	}
+	return d
+}
+
+// tryListen opens and closes ICMP socket. IPv6 is tried, when IPv4 fails.
+func tryListen(privileged bool) error {
+	lc := pinger.ListenConfig{Privileged: privileged}
+	c, err := lc.Listen(pinger.ProtocolIpv4)
+	if err != nil {
+		var err6 error
+		if c, err6 = lc.Listen(pinger.ProtocolIpv6); err6 != nil {
+			return err
+		}
+	}
+	return c.Close()
+}
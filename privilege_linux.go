@@ -0,0 +1,83 @@
+package multiping
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	capNetRaw      = 13 // CAP_NET_RAW bit of capability sets
+	procStatus     = "/proc/self/status"
+	pingGroupRange = "/proc/sys/net/ipv4/ping_group_range"
+)
+
+// checkPrivileged checks, whether process may open raw sockets
+func checkPrivileged() error {
+	euid := os.Geteuid()
+	if euid == 0 {
+		return nil
+	}
+
+	status, err := os.ReadFile(procStatus)
+	if err != nil {
+		return fmt.Errorf("effective UID %d is not root and capabilities are unknown: %v", euid, err)
+	}
+	caps, err := parseCapEff(string(status))
+	if err != nil {
+		return fmt.Errorf("effective UID %d is not root and capabilities are unknown: %v", euid, err)
+	}
+	if caps&(1<<capNetRaw) == 0 {
+		return fmt.Errorf("effective UID %d is not root and CAP_NET_RAW is not effective", euid)
+	}
+	return nil
+}
+
+// checkUnprivileged checks, whether process groups are allowed to open datagram ICMP sockets
+func checkUnprivileged() error {
+	content, err := os.ReadFile(pingGroupRange)
+	if err != nil {
+		return fmt.Errorf("ping_group_range is unknown: %v", err)
+	}
+	low, high, err := parseGroupRange(string(content))
+	if err != nil {
+		return fmt.Errorf("ping_group_range is unknown: %v", err)
+	}
+
+	groups, _ := os.Getgroups()
+	groups = append(groups, os.Getegid())
+	for _, gid := range groups {
+		if uint64(gid) >= low && uint64(gid) <= high {
+			return nil
+		}
+	}
+	return fmt.Errorf("groups %v are not in ping_group_range %d %d", groups, low, high)
+}
+
+// parseCapEff returns effective capabilities from /proc/<pid>/status content
+func parseCapEff(status string) (uint64, error) {
+	scanner := bufio.NewScanner(strings.NewReader(status))
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "CapEff:") {
+			return strconv.ParseUint(strings.TrimSpace(line[len("CapEff:"):]), 16, 64)
+		}
+	}
+	return 0, fmt.Errorf("CapEff is missing")
+}
+
+// parseGroupRange parses ping_group_range content. Range "1 0" allows no groups.
+func parseGroupRange(content string) (low, high uint64, err error) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid range %q", content)
+	}
+	if low, err = strconv.ParseUint(fields[0], 10, 32); err != nil {
+		return 0, 0, err
+	}
+	if high, err = strconv.ParseUint(fields[1], 10, 32); err != nil {
+		return 0, 0, err
+	}
+	return low, high, nil
+}
@@ -0,0 +1,23 @@
+package multiping
+
+import (
+	"testing"
+)
+
+func TestParseProc(t *testing.T) {
+	caps, err := parseCapEff("Name:\tping\nCapInh:\t0000000000000000\nCapEff:\t0000000000002000\n")
+	if err != nil || caps&(1<<capNetRaw) == 0 {
+		t.Errorf("CAP_NET_RAW not parsed: %x %v", caps, err)
+	}
+	if _, err = parseCapEff("Name:\tping\n"); err == nil {
+		t.Errorf("Missing CapEff not detected")
+	}
+
+	low, high, err := parseGroupRange("1\t0\n")
+	if err != nil || low != 1 || high != 0 {
+		t.Errorf("Invalid group range %d %d %v", low, high, err)
+	}
+	if _, _, err = parseGroupRange("0"); err == nil {
+		t.Errorf("Invalid group range accepted")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package multiping
+
+// checkPrivileged can't tell in advance, mode is checked by opening a socket
+func checkPrivileged() error {
+	return nil
+}
+
+// checkUnprivileged can't tell in advance, mode is checked by opening a socket
+func checkUnprivileged() error {
+	return nil
+}
@@ -0,0 +1,28 @@
+package multiping
+
+import (
+	"testing"
+)
+
+func TestDetectMode(t *testing.T) {
+	d := DetectMode()
+	if d.Mode == ModeNone {
+		t.Fatalf("No ICMP socket mode detected: %v", d)
+	}
+
+	mp, err := NewWithOptions()
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	if mp.Mode() != d.Mode || mp.Detection().Explicit {
+		t.Errorf("Unexpected mode %v", mp.Detection())
+	}
+
+	mp, err = New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	if mp.Mode() != ModeUnprivileged || !mp.Detection().Explicit {
+		t.Errorf("Unexpected mode %v", mp.Detection())
+	}
+}
@@ -0,0 +1,117 @@
+package multiping
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// PortState tells how port answered TCP or UDP probe
+type PortState int
+
+const (
+	PortNone    PortState = iota // not a port probe
+	PortOpen                     // connection accepted
+	PortRefused                  // connection refused, host is up anyway
+)
+
+func (s PortState) String() string {
+	switch s {
+	case PortNone:
+		return "none"
+	case PortOpen:
+		return "open"
+	case PortRefused:
+		return "refused"
+	}
+	return fmt.Sprintf("PortState(%d)", int(s))
+}
+
+// Prober sends probes of some protocol. Round drives it the same way for every protocol,
+// so batching, pacing, timeouts, statistics and streaming do not depend on protocol.
+// Prober lives for a single round, see ProberFactory.
+//
+// Round calls Prepare, Send and Receive from different goroutines, so Prepare and Send of different
+// probes run concurrently and must be safe for that. Match is called by the goroutine calling Receive.
+type Prober interface {
+	// Prepare builds probe with sequence seq to addr
+	Prepare(addr netip.Addr, seq uint16, opts ProbeOptions) (*pinger.Packet, error)
+
+	// Send sends prepared probe
+	Send(pkt *pinger.Packet) error
+
+	// Receive blocks until some packet is received. It returns ErrProberClosed after Close.
+	Receive() (*pinger.Packet, error)
+
+	// Match returns result of probe answered by received packet or false, if packet answers no probe.
+	// Result Kind is ProbeReply, ProbeError, ProbeCorrupted, ProbeTruncated or ProbeForged.
+	// ProbeError result must hold *pinger.IcmpError in Err. Result TOS must be -1, when TOS of reply
+	// is unknown: 0 is a valid TOS and it would be counted in DSCP and remarked reply statistics.
+	Match(pkt *pinger.Packet) (ProbeResult, bool)
+
+	// Close stops prober and releases its sockets
+	Close()
+}
+
+// ProberFactory opens prober for a single round with cfg settings, see RoundConfig.Prober
+type ProberFactory func(mp *MultiPing, cfg *RoundConfig) (Prober, error)
+
+// newProber returns prober of cfg protocol
+func (mp *MultiPing) newProber(cfg *RoundConfig, sess *session) (Prober, error) {
+	switch {
+	case cfg.Prober != nil:
+		return cfg.Prober(mp, cfg)
+	case cfg.TCP && mp.Mode() == ModePrivileged:
+		return mp.newSYNProber(cfg, sess)
+	case cfg.TCP:
+		return mp.newConnectProber(cfg, "tcp"), nil
+	case cfg.UDP && mp.Mode() == ModePrivileged:
+		return mp.newUDPProber(cfg), nil
+	case cfg.UDP:
+		return mp.newConnectProber(cfg, "udp"), nil
+	case cfg.DNS != nil:
+		return mp.newDNSProber(cfg, sess)
+	}
+	return mp.newICMPProber(cfg), nil
+}
+
+// setProber opens prober of round protocol and reserves sequences of round probes
+func (r *round) setProber() error {
+	p, err := r.mp.newProber(&r.cfg, r.sess)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSocketSetup, err)
+	}
+
+	// Only ICMP probers get replies routed by sequence, others just reserve sequences
+	h, _ := p.(replyHandler)
+	if r.cfg.Interface != nil {
+		// Extended echo has 8 bit sequence, replies are routed by it
+		seq, ok := r.mp.registerShort(h, r.cfg.Count)
+		if !ok {
+			p.Close()
+			return fmt.Errorf("%w: no %d free 8 bit sequences for extended echo requests", ErrPartialSend, r.cfg.Count)
+		}
+		r.sequence = seq
+	} else {
+		r.sequence = r.mp.register(h, r.cfg.Count)
+	}
+
+	r.prober = p
+	return nil
+}
+
+// receiveProbes passes matched prober packets to round, until prober is closed
+func (r *round) receiveProbes() {
+	defer close(r.proberDone)
+
+	for {
+		pkt, err := r.prober.Receive()
+		if err != nil {
+			return
+		}
+		if res, ok := r.prober.Match(pkt); ok {
+			r.handleResult(res)
+		}
+	}
+}
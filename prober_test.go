@@ -0,0 +1,118 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// loopProber answers its own probes: up host replies, down host gets ICMP error, lost host gets nothing
+type loopProber struct {
+	up, down netip.Addr
+	packets  chan *pinger.Packet
+	done     chan struct{}
+}
+
+func (p *loopProber) Prepare(addr netip.Addr, seq uint16, opts ProbeOptions) (*pinger.Packet, error) {
+	return &pinger.Packet{Addr: addr, Seq: seq}, nil
+}
+
+func (p *loopProber) Send(pkt *pinger.Packet) error {
+	go func() {
+		select {
+		case p.packets <- pkt:
+		case <-p.done:
+		}
+	}()
+	return nil
+}
+
+func (p *loopProber) Receive() (*pinger.Packet, error) {
+	select {
+	case pkt := <-p.packets:
+		return pkt, nil
+	case <-p.done:
+		return nil, ErrProberClosed
+	}
+}
+
+func (p *loopProber) Match(pkt *pinger.Packet) (ProbeResult, bool) {
+	switch pkt.Addr {
+	case p.up:
+		return ProbeResult{Kind: ProbeReply, Addr: pkt.Addr, Seq: pkt.Seq, RTT: time.Millisecond, TOS: -1}, true
+	case p.down:
+		icmpErr := &pinger.IcmpError{Type: 3, Code: 1, Dest: pkt.Addr, Router: p.up}
+		return ProbeResult{Kind: ProbeError, Addr: pkt.Addr, Seq: pkt.Seq, Err: icmpErr}, true
+	}
+	return ProbeResult{}, false
+}
+
+func (p *loopProber) Close() {
+	close(p.done)
+}
+
+func TestCustomProber(t *testing.T) {
+	mp, err := NewWithOptions(WithPrivileged(false), WithTimeout(200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Init failed: %s", err)
+	}
+
+	up := netip.MustParseAddr("192.0.2.1")
+	down := netip.MustParseAddr("192.0.2.2")
+	lost := netip.MustParseAddr("192.0.2.3")
+
+	cfg := mp.Config()
+	cfg.Count = 3
+	cfg.Interval = 20 * time.Millisecond
+	cfg.Prober = func(_ *MultiPing, _ *RoundConfig) (Prober, error) {
+		return &loopProber{up: up, down: down, packets: make(chan *pinger.Packet), done: make(chan struct{})}, nil
+	}
+
+	data := pingdata.NewPingData()
+	data.Add(up, down, lost)
+	summary, err := mp.PingConfig(context.Background(), data, cfg)
+	if err != nil {
+		t.Fatalf("Ping failed: %s", err)
+	}
+	if summary.Sent != 9 || summary.Received != 3 || summary.Errors != 3 || summary.Unmatched != 0 {
+		t.Errorf("Invalid summary %+v", summary)
+	}
+
+	if stats, _ := data.Get(up); stats.Loss() != 0 || stats.Rtt() != time.Millisecond {
+		t.Errorf("Up host loss %f, rtt %s", stats.Loss(), stats.Rtt())
+	}
+	if stats, _ := data.Get(down); stats.Loss() != 1 || stats.Errors() != 3 {
+		t.Errorf("Down host loss %f, errors %d", stats.Loss(), stats.Errors())
+	}
+	if stats, _ := data.Get(lost); stats.Loss() != 1 {
+		t.Errorf("Lost host loss %f", stats.Loss())
+	}
+
+	// Streaming reports every probe of custom prober too
+	kinds := make(map[ProbeKind]int)
+	for res := range mp.StreamConfig(context.Background(), []netip.Addr{up, down, lost}, cfg) {
+		kinds[res.Kind]++
+	}
+	if kinds[ProbeReply] != 3 || kinds[ProbeError] != 3 || kinds[ProbeTimeout] != 3 {
+		t.Errorf("Invalid streamed results %v", kinds)
+	}
+
+	// Factory error fails round
+	cfg.Prober = func(_ *MultiPing, _ *RoundConfig) (Prober, error) {
+		return nil, errors.New("no sockets")
+	}
+	if _, err = mp.PingConfig(context.Background(), data, cfg); !errors.Is(err, ErrSocketSetup) {
+		t.Errorf("Expected socket setup error, got %v", err)
+	}
+
+	// Custom prober replaces built-in probes
+	cfg.TCP = true
+	if _, err = mp.PingConfig(context.Background(), data, cfg); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected invalid option error, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package multiping
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// probe is a sent echo request, waiting for reply
+type probe struct {
+	addr     netip.Addr
+	seq      uint16
+	deadline time.Time
+	answered bool // answered or failed to send
+}
+
+type probeKey struct {
+	addr netip.Addr
+	seq  uint16
+}
+
+// probeQueue tracks outstanding echo requests of a round.
+// Requests are queued in send order and all of them have the same timeout,
+// thus the first request in queue always expires first.
+type probeQueue struct {
+	mu          sync.Mutex
+	queue       []*probe
+	index       map[probeKey]*probe
+	outstanding int          // sent, but neither answered nor expired requests
+	sendDone    bool         // all requests are sent
+	finished    bool         // all requests are answered or expired
+	early       bool         // finished before the last request expired
+	last        time.Time    // deadline of the last request
+	timer       *time.Timer  // expires request in queue head
+	onFinish    func()       // called when all requests are answered or expired, may be nil
+	onExpire    func(*probe) // called for every expired request, may be nil
+}
+
+func newProbeQueue(onFinish func(), onExpire func(*probe)) *probeQueue {
+	return &probeQueue{
+		index:    make(map[probeKey]*probe),
+		onFinish: onFinish,
+		onExpire: onExpire,
+	}
+}
+
+// add registers sent echo request
+func (q *probeQueue) add(addr netip.Addr, seq uint16, deadline time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	p := &probe{addr: addr, seq: seq, deadline: deadline}
+	q.queue = append(q.queue, p)
+	q.index[probeKey{addr, seq}] = p
+	q.outstanding++
+	q.last = deadline
+
+	// Arm timer for the new queue head
+	if len(q.queue) == 1 {
+		if q.timer == nil {
+			q.timer = time.AfterFunc(time.Until(deadline), q.expire)
+		} else {
+			q.timer.Reset(time.Until(deadline))
+		}
+	}
+}
+
+// done marks echo request as answered or failed to send.
+// Returns false, if request is not outstanding anymore: it is already answered or expired.
+func (q *probeQueue) done(addr netip.Addr, seq uint16) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	p, ok := q.index[probeKey{addr, seq}]
+	if !ok || p.answered {
+		return false
+	}
+	// Deadline passed, but timer has not fired yet. Request expires anyway.
+	if !p.deadline.After(time.Now()) {
+		return false
+	}
+	p.answered = true
+	delete(q.index, probeKey{addr, seq})
+	q.outstanding--
+	q.check()
+	return true
+}
+
+// close tells that no more requests will be added
+func (q *probeQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.sendDone = true
+	q.check()
+}
+
+// stop releases timer. Queue must not be used afterwards
+func (q *probeQueue) stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.timer != nil {
+		q.timer.Stop()
+	}
+	q.finished = true
+}
+
+// expire removes answered and expired requests from queue head
+func (q *probeQueue) expire() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.finished {
+		return
+	}
+
+	now := time.Now()
+	for len(q.queue) > 0 {
+		p := q.queue[0]
+		if !p.answered && p.deadline.After(now) {
+			break
+		}
+
+		q.queue[0] = nil
+		q.queue = q.queue[1:]
+		if !p.answered {
+			q.expireProbe(p)
+		}
+	}
+
+	if len(q.queue) > 0 {
+		q.timer.Reset(time.Until(q.queue[0].deadline))
+	}
+	q.check()
+}
+
+// flush expires all outstanding requests regardless of their deadline
+func (q *probeQueue) flush() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, p := range q.queue {
+		if !p.answered {
+			q.expireProbe(p)
+		}
+	}
+	q.queue = nil
+}
+
+// expireProbe removes outstanding request. Must be called with q.mu locked
+func (q *probeQueue) expireProbe(p *probe) {
+	delete(q.index, probeKey{p.addr, p.seq})
+	q.outstanding--
+	p.answered = true
+	if q.onExpire != nil {
+		q.onExpire(p)
+	}
+}
+
+// check if round is finished. Must be called with q.mu locked
+func (q *probeQueue) check() {
+	if q.finished || !q.sendDone || q.outstanding > 0 {
+		return
+	}
+
+	q.finished = true
+	q.early = time.Now().Before(q.last)
+	if q.onFinish != nil {
+		q.onFinish()
+	}
+}
@@ -0,0 +1,36 @@
+package multiping
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestProbeQueue(t *testing.T) {
+	finished := make(chan struct{})
+	expired := 0
+	q := newProbeQueue(func() { close(finished) }, func(*probe) { expired++ })
+	defer q.stop()
+
+	ip := netip.MustParseAddr("127.0.0.1")
+	start := time.Now()
+	q.add(ip, 1, start.Add(50*time.Millisecond))
+	q.add(ip, 2, start.Add(time.Second))
+	q.close()
+
+	// Second request is answered, first one must expire on its own deadline
+	q.done(ip, 2)
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("Queue did not finish")
+	}
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("Unexpected finish time %s", elapsed)
+	}
+	if !q.early || q.outstanding != 0 || expired != 1 {
+		t.Errorf("Unexpected queue state early=%t outstanding=%d expired=%d", q.early, q.outstanding, expired)
+	}
+}
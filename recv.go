@@ -1,12 +1,15 @@
 package multiping
 
 import (
+	"net/netip"
+	"time"
+
 	"github.com/drgkaleda/go-multiping/pinger"
 )
 
-func (mp *MultiPing) batchRecvICMP(proto pinger.ProtocolVersion) {
+func (mp *MultiPing) batchRecvICMP(sess *session, proto pinger.ProtocolVersion) {
 	defer func() {
-		mp.wg.Done()
+		sess.wg.Done()
 	}()
 
 	for {
@@ -15,23 +18,157 @@ func (mp *MultiPing) batchRecvICMP(proto pinger.ProtocolVersion) {
 			if err == pinger.ErrInvalidConn {
 				return
 			}
+			// Packet with unparsable source, nothing to do with it
+			continue
 		}
 
-		mp.rxChan <- pkt
+		sess.rxChan <- pkt
 	}
 }
 
 // This function runs in goroutine and nobody is interested in return errors
 // Discard errors silently
-func (mp *MultiPing) batchProcessPacket() {
-	for recv := range mp.rxChan {
+func (mp *MultiPing) batchProcessPacket(sess *session) {
+	defer close(sess.rxDone)
+
+	for recv := range sess.rxChan {
 		pingStats := mp.pinger.ParsePacket(recv)
-		if pingStats.Tracker != mp.Tracker {
+		// ICMP errors quote only echo header, timestamp and extended echo replies have no payload,
+		// there is no tracker to check
+		if !pingStats.Valid || (pingStats.Error == nil && pingStats.Timestamp == nil && pingStats.Extended == nil &&
+			pingStats.Tracker != mp.Tracker) {
 			continue
 		}
 
-		if stats, ok := mp.pingData.Get(recv.Addr); ok {
-			stats.Recv(pingStats.Seq, pingStats.RTT)
+		// Errors about UDP probes have no sequence, they are routed by probe source port
+		if icmpErr := pingStats.Error; icmpErr != nil && icmpErr.UDP {
+			if h, ok := mp.portHandler(icmpErr.SourcePort); ok {
+				h.handleReply(recv, pingStats)
+			}
+			continue
 		}
+
+		// Route reply to the round, which sent the request
+		if h, ok := mp.handler(pingStats.Seq); ok {
+			h.handleReply(recv, pingStats)
+		}
+	}
+}
+
+// handleResult registers result of probe matched by prober
+func (r *round) handleResult(res ProbeResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	// Forged reply must not resolve request, real reply may still come
+	if res.Kind == ProbeForged {
+		r.summary.Forged++
+		if stats, ok := r.data.Get(res.Addr); ok {
+			stats.RecvForged()
+		}
+		return
+	}
+
+	if r.trace != nil {
+		r.traceReply(res)
+		return
+	}
+
+	stats, ok := r.data.Get(res.Addr)
+	if !ok {
+		r.summary.Unmatched++
+		return
+	}
+
+	icmpErr, isICMP := res.Err.(*pinger.IcmpError)
+	switch res.Kind {
+	case ProbeReply, ProbeCorrupted, ProbeTruncated:
+	case ProbeError:
+		ok = isICMP
+	default:
+		ok = false
+	}
+	// Late reply must not be counted, timeout of its request is already reported
+	if !ok || (r.probes != nil && !r.probes.done(res.Addr, res.Seq)) {
+		r.summary.Unmatched++
+		return
+	}
+
+	switch res.Kind {
+	case ProbeReply:
+		if res.Port == PortRefused {
+			ok = stats.RecvRefused(res.Seq, res.RTT)
+		} else {
+			ok = stats.Recv(res.Seq, res.RTT)
+		}
+	case ProbeError:
+		ok = stats.RecvError(res.Seq, icmpErr.Type, icmpErr.Code, icmpErr.Router)
+	case ProbeCorrupted:
+		ok = stats.RecvCorrupted(res.Seq)
+	case ProbeTruncated:
+		ok = stats.RecvTruncated(res.Seq)
+	}
+	if !ok {
+		r.summary.Unmatched++
+		return
+	}
+
+	switch res.Kind {
+	case ProbeReply:
+		r.summary.Received++
+		if res.Port == PortRefused {
+			r.summary.Refused++
+		}
+		if old := stats.Distance(); stats.SetTTL(res.TTL) {
+			r.changes = append(r.changes, DistanceChange{Addr: res.Addr, Old: old, New: stats.Distance(), TTL: res.TTL})
+		}
+		if stats.SetTOS(r.mp.sentTOS(res.Addr, r.cfg.probe(res.Addr).TOS), res.TOS) {
+			r.summary.Remarked++
+		}
+		if ts := res.Timestamp; ts != nil && ts.Standard() {
+			stats.SetClock(ts.Offset(), ts.Forward(), ts.Return())
+		}
+		if d := res.DNS; d != nil {
+			stats.SetRcode(int(d.Rcode))
+		}
+	case ProbeError:
+		r.summary.Errors++
+	case ProbeCorrupted:
+		r.summary.Corrupted++
+	case ProbeTruncated:
+		r.summary.Truncated++
+	}
+
+	if res.Time.IsZero() {
+		res.Time = time.Now()
+	}
+	r.emit(res)
+}
+
+// checkPayload compares reply payload with echo request of probe size.
+// Returns ProbeReply for intact payload, ProbeTruncated or ProbeCorrupted otherwise.
+func (mp *MultiPing) checkPayload(pingStats pinger.IcmpStats, probe int) ProbeKind {
+	size := mp.payloadSize(probe)
+	switch {
+	case pingStats.Size < size:
+		return ProbeTruncated
+	case pingStats.Size > size || pingStats.Corrupted:
+		return ProbeCorrupted
+	}
+	return ProbeReply
+}
+
+// sentTOS returns TOS (traffic class) of echo requests to addr. Positive probe TOS overrides socket TOS.
+func (mp *MultiPing) sentTOS(addr netip.Addr, probe int) int {
+	if probe > 0 {
+		return probe
+	}
+	if addr.Is4() {
+		return mp.listen4.TOS
 	}
+	return mp.listen6.TOS
 }
@@ -0,0 +1,125 @@
+package multiping
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// round is a single in-flight ping of PingData hosts.
+// Several rounds may run at the same time, sharing the same session.
+type round struct {
+	mp   *MultiPing
+	sess *session
+	cfg  RoundConfig
+
+	parent  context.Context    // caller context
+	ctx     context.Context    // context for timeouting
+	cancel  context.CancelFunc // cancels round
+	timeout *time.Timer        // cancels round, when Timeout passes after last echo request
+
+	sequence uint16 // ICMP seq number of the first echo request in round, reserved by setProber
+	txChan   chan *pinger.Packet
+	wg       sync.WaitGroup // sender goroutines
+
+	// Locks data statistics and counters below while round is running
+	mu            sync.Mutex
+	data          *pingdata.PingData
+	closed        bool         // round is finished, replies must not touch data anymore
+	summary       RoundSummary // packet counters
+	probes        *probeQueue  // outstanding requests, used with FinishEarly and streaming
+	unsupported   int          // hosts skipped, because of missing address family connection
+	prepareFailed int          // hosts, for which echo request could not be prepared
+
+	events  chan<- ProbeResult // results of every request, when streaming
+	trace   *tracer            // collects hops, when tracerouting
+	changes []DistanceChange   // reported after round ends
+
+	prober     Prober        // sends probes of round protocol
+	proberDone chan struct{} // closed when receiveProbes exits
+}
+
+func newRound(ctx context.Context, mp *MultiPing, sess *session, data *pingdata.PingData, cfg RoundConfig) *round {
+	r := &round{
+		mp:     mp,
+		sess:   sess,
+		cfg:    cfg,
+		parent: ctx,
+		data:   data,
+		txChan: make(chan *pinger.Packet),
+	}
+
+	// Round is cancelled by sender, when timeout passes after the last echo request
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	if cfg.FinishEarly {
+		r.probes = newProbeQueue(r.cancel, nil)
+	}
+	return r
+}
+
+// run pings all hosts and blocks until round is over
+func (r *round) run() {
+	start := time.Now()
+	r.proberDone = make(chan struct{})
+	go r.receiveProbes()
+
+	// 2 Sender goroutine workers:
+	// one prepares message and other actually sends it
+	r.wg.Add(2)
+	go r.batchSendIcmp()
+	go r.batchPrepareIcmp()
+
+	// wait for timeout and sender goroutines to terminate
+	<-r.ctx.Done()
+	r.wg.Wait()
+	r.timeout.Stop()
+
+	// Stop routing replies and prevent from possible data corruption in future
+	r.mp.unregister(r.sequence, r.cfg.Count)
+	r.prober.Close()
+	<-r.proberDone
+	r.mu.Lock()
+	r.closed = true
+	if r.probes != nil {
+		// Requests still waiting for reply are timed out now, unless caller gave up
+		if r.events != nil && r.parent.Err() == nil {
+			r.probes.flush()
+		}
+		r.probes.stop()
+		r.summary.Early = r.probes.early
+	}
+	r.mu.Unlock()
+
+	// Hosts which were not sent are failures too
+	r.summary.SendFailed += r.unsupported + r.prepareFailed
+	r.summary.Duration = time.Since(start)
+
+	// Round is closed, so changes are not modified anymore and callback runs without locks
+	if fn := r.mp.OnDistanceChange; fn != nil {
+		for _, change := range r.changes {
+			fn(change)
+		}
+	}
+}
+
+// err checks finished round for errors, that caller should be aware of
+func (r *round) err(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if r.unsupported > 0 {
+		return fmt.Errorf("%w: %d hosts were not pinged", ErrUnsupportedFamily, r.unsupported)
+	}
+
+	if r.summary.SendFailed > 0 {
+		return fmt.Errorf("%w: %d of %d requests failed", ErrPartialSend,
+			r.summary.SendFailed, r.summary.SendFailed+r.summary.Sent)
+	}
+
+	return nil
+}
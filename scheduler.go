@@ -0,0 +1,152 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+)
+
+// ErrSchedulerRunning is returned when Run is called for already running scheduler
+var ErrSchedulerRunning = errors.New("scheduler is already running")
+
+// Scheduler periodically pings targets of registered clients through shared MultiPing
+// and passes results to client PingProcess. Every client has its own targets and period,
+// rounds of different clients run in parallel.
+type Scheduler struct {
+	mp *MultiPing
+
+	// Locks clients and running state
+	mu      sync.Mutex
+	clients map[int]*scheduledClient
+	lastID  int
+	ctx     context.Context // non nil while scheduler is running
+	wg      sync.WaitGroup  // client goroutines
+}
+
+type scheduledClient struct {
+	client  PingClient
+	targets []netip.Addr
+	period  time.Duration
+	cancel  context.CancelFunc // stops client goroutine, nil when not started
+}
+
+// NewScheduler creates scheduler, which pings with mp
+func NewScheduler(mp *MultiPing) *Scheduler {
+	return &Scheduler{
+		mp:      mp,
+		clients: make(map[int]*scheduledClient),
+	}
+}
+
+// Register adds client, which targets are pinged every period.
+// Returned id is used to unregister client. Period must be positive.
+// If scheduler is running, client is started immediately.
+func (s *Scheduler) Register(client PingClient, period time.Duration, targets ...netip.Addr) (int, error) {
+	if period <= 0 {
+		return 0, fmt.Errorf("%w: period %v must be positive", ErrInvalidOption, period)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	c := &scheduledClient{
+		client:  client,
+		targets: append([]netip.Addr(nil), targets...),
+		period:  period,
+	}
+	s.clients[s.lastID] = c
+
+	if s.ctx != nil {
+		s.start(c)
+	}
+	return s.lastID, nil
+}
+
+// Unregister removes client. Ping in progress is cancelled and client will not be called
+// anymore. PingProcess call, which is already running, is not waited for and may still finish
+// after Unregister returns.
+func (s *Scheduler) Unregister(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.clients[id]; ok {
+		if c.cancel != nil {
+			c.cancel()
+		}
+		delete(s.clients, id)
+	}
+}
+
+// Run pings clients until ctx is cancelled.
+// It is blocking function and always returns non nil error.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx != nil {
+		s.mu.Unlock()
+		return ErrSchedulerRunning
+	}
+	s.ctx = ctx
+	for _, c := range s.clients {
+		s.start(c)
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.mu.Lock()
+	s.ctx = nil
+	for _, c := range s.clients {
+		c.cancel = nil
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return ctx.Err()
+}
+
+// start runs client goroutine. Must be called with s.mu locked
+func (s *Scheduler) start(c *scheduledClient) {
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(s.ctx)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.runClient(ctx, c)
+	}()
+}
+
+// runClient pings client targets every period
+func (s *Scheduler) runClient(ctx context.Context, c *scheduledClient) {
+	ticker := time.NewTicker(c.period)
+	defer ticker.Stop()
+
+	for {
+		data := pingdata.NewPingData()
+		data.Add(c.targets...)
+
+		// Errors are reflected in data as loss, client gets results anyway
+		s.mp.PingContext(ctx, data)
+
+		// Unregister cancels ctx with s.mu locked, so no new client call starts after it returns
+		s.mu.Lock()
+		stopped := ctx.Err() != nil
+		s.mu.Unlock()
+		if stopped {
+			return
+		}
+		c.client.PingProcess(data)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
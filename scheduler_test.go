@@ -0,0 +1,84 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+)
+
+type testClient struct {
+	sync.Mutex
+	calls    int
+	received uint
+}
+
+func (c *testClient) PingProcess(data *pingdata.PingData) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.calls++
+	data.Iterate(func(ip netip.Addr, val *pingdata.PingStats) {
+		c.received += val.Received()
+	})
+}
+
+func (c *testClient) stats() (int, uint) {
+	c.Lock()
+	defer c.Unlock()
+	return c.calls, c.received
+}
+
+func TestScheduler(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.Timeout = 20 * time.Millisecond
+
+	fast, slow, late := &testClient{}, &testClient{}, &testClient{}
+
+	s := NewScheduler(mp)
+	if _, err = s.Register(fast, 0); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Expected invalid option error for zero period, got %v", err)
+	}
+	fastID, _ := s.Register(fast, 50*time.Millisecond, netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("127.0.0.2"))
+	s.Register(slow, time.Hour, netip.MustParseAddr("127.0.0.3"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := s.Run(ctx); err != context.DeadlineExceeded {
+			t.Errorf("Unexpected scheduler error %v", err)
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	if err := s.Run(ctx); err != ErrSchedulerRunning {
+		t.Errorf("Scheduler started twice")
+	}
+	s.Register(late, 50*time.Millisecond, netip.MustParseAddr("127.0.0.4"))
+	s.Unregister(fastID)
+	fastCalls, _ := fast.stats()
+	wg.Wait()
+
+	calls, received := fast.stats()
+	// Client call in progress may finish after unregister
+	if calls < 3 || calls > fastCalls+1 || received != uint(2*calls) {
+		t.Errorf("Unexpected fast client calls=%d received=%d", calls, received)
+	}
+	if calls, received = slow.stats(); calls != 1 || received != 1 {
+		t.Errorf("Unexpected slow client calls=%d received=%d", calls, received)
+	}
+	if calls, _ = late.stats(); calls < 2 {
+		t.Errorf("Client registered at runtime was not called: %d", calls)
+	}
+}
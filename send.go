@@ -2,34 +2,150 @@ package multiping
 
 import (
 	"net/netip"
+	"time"
 
 	"github.com/drgkaleda/go-multiping/pingdata"
-	"github.com/drgkaleda/go-multiping/pinger"
 )
 
-func (mp *MultiPing) batchPrepareIcmp() {
-	defer close(mp.txChan)
+func (r *round) batchPrepareIcmp() {
+	defer r.wg.Done()
+	defer close(r.txChan)
 
-	mp.pingData.Iterate(func(addr netip.Addr, stats *pingdata.PingStats) {
-		pkt, err := mp.pinger.PrepareICMP(addr, mp.sequence)
-		if err == nil {
-			stats.Send(mp.sequence)
-			mp.txChan <- pkt
-		}
+	hosts := make([]netip.Addr, 0, r.data.Count())
+	r.data.Iterate(func(addr netip.Addr, _ *pingdata.PingStats) {
+		hosts = append(hosts, addr)
 	})
+	if r.mp.Pacing.PrefixRate > 0 {
+		hosts = r.mp.Pacing.interleave(hosts)
+	}
+
+	for i := 0; i < r.cfg.Count; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(r.cfg.Interval):
+			case <-r.ctx.Done():
+				return
+			}
+		}
 
+		if !r.batchPrepareSeq(hosts, r.sequence+uint16(i)) {
+			return
+		}
+	}
 }
 
-func (mp *MultiPing) batchSendIcmp() {
-	var err error
-	defer mp.wg.Done()
+// batchPrepareSeq prepares echo requests with sequence seq for all hosts.
+// Returns false if round was cancelled meanwhile.
+func (r *round) batchPrepareSeq(hosts []netip.Addr, seq uint16) bool {
+	spread := newSpread(r.cfg.Spread, len(hosts))
+
+	for _, addr := range hosts {
+		// There is no IPv6 timestamp request
+		if !r.sess.supported(addr) || (r.cfg.Timestamp && !addr.Is4()) {
+			r.unsupported++
+			r.emit(ProbeResult{Kind: ProbeSendError, Addr: addr, Seq: seq, Time: time.Now(), Err: ErrUnsupportedFamily})
+			continue
+		}
+
+		if r.trace != nil {
+			r.mu.Lock()
+			reached := r.trace.reached(addr, r.ttl(seq))
+			r.mu.Unlock()
+			if reached {
+				continue
+			}
+		}
 
-	for pkt := range mp.txChan {
-		err = mp.pinger.SendPacket(pkt)
+		// Wait before preparing, as request contains send timestamp
+		if !r.mp.wait(r.ctx, spread, addr) {
+			return false
+		}
+
+		opts := r.cfg.probe(addr)
+		pkt, err := r.prober.Prepare(addr, seq, opts)
 		if err != nil {
-			if err == pinger.ErrInvalidConn {
-				break
+			r.prepareFailed++
+			r.emit(ProbeResult{Kind: ProbeSendError, Addr: addr, Seq: seq, Time: time.Now(), Err: err})
+			continue
+		}
+		if r.trace != nil {
+			pkt.TTL = r.ttl(seq)
+		}
+
+		r.mu.Lock()
+		if stats, ok := r.data.Get(addr); ok {
+			stats.Send(seq)
+		}
+		if r.trace != nil {
+			r.trace.send(addr, pkt.TTL, seq)
+		}
+		r.mu.Unlock()
+
+		select {
+		case r.txChan <- pkt:
+		case <-r.ctx.Done():
+			// Round is over, no reason to prepare the rest
+			return false
+		}
+	}
+
+	return true
+}
+
+// supported checks if there is an open connection for addr address family
+func (sess *session) supported(addr netip.Addr) bool {
+	if addr.Is4() {
+		return sess.conn4 != nil
+	}
+	if addr.Is6() {
+		return sess.conn6 != nil
+	}
+	return false
+}
+
+func (r *round) batchSendIcmp() {
+	defer r.wg.Done()
+
+	// Do not stop on errors: prepare goroutine must be able to flush txChan
+	for pkt := range r.txChan {
+		// Reply may arrive before SendPacket returns, so register request beforehand
+		if r.probes != nil {
+			r.probes.add(pkt.Addr, pkt.Seq, time.Now().Add(r.cfg.Timeout))
+		}
+		if r.trace != nil {
+			r.mu.Lock()
+			r.trace.sent[probeKey{addr: pkt.Addr, seq: pkt.Seq}] = time.Now()
+			r.mu.Unlock()
+		}
+
+		if err := r.prober.Send(pkt); err != nil {
+			r.summary.SendFailed++
+			if r.probes != nil {
+				r.probes.done(pkt.Addr, pkt.Seq)
 			}
+			r.emit(ProbeResult{Kind: ProbeSendError, Addr: pkt.Addr, Seq: pkt.Seq, Time: time.Now(), Err: err})
+			continue
 		}
+		r.summary.Sent++
+	}
+
+	if r.probes != nil {
+		r.probes.close()
+	}
+
+	// Everything is sent, wait for the last replies
+	r.timeout = time.AfterFunc(r.cfg.Timeout, r.cancel)
+}
+
+// payloadSize returns payload size of echo requests. Positive probe size overrides pinger Size.
+func (mp *MultiPing) payloadSize(probe int) int {
+	size := probe
+	if size <= 0 {
+		size = mp.pinger.Size
+	}
+	if minSize := mp.pinger.MinPayload(); size < minSize {
+		// Timestamp, tracker and HMAC are always sent
+		size = minSize
 	}
+	return size
 }
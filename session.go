@@ -0,0 +1,183 @@
+package multiping
+
+import (
+	"sync"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// replyHandler consumes echo replies. Replies are routed to it by echo request sequence.
+type replyHandler interface {
+	handleReply(pkt *pinger.Packet, stats pinger.IcmpStats)
+}
+
+// session is a pair of sockets shared by all concurrently running rounds.
+// It is opened by the first round and closed, when the last round ends.
+type session struct {
+	conn4  *pinger.Conn
+	conn6  *pinger.Conn
+	users  int                 // count of rounds using session
+	wg     sync.WaitGroup      // receiver goroutines
+	rxChan chan *pinger.Packet // received packets
+	rxDone chan struct{}       // closed when batchProcessPacket exits
+}
+
+// acquire returns opened session. Caller must release it after use.
+func (mp *MultiPing) acquire() (*session, error) {
+	mp.sessMu.Lock()
+	defer mp.sessMu.Unlock()
+
+	if mp.sess != nil {
+		mp.sess.users++
+		return mp.sess, nil
+	}
+
+	c4, c6, err := mp.openConns()
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &session{
+		conn4:  c4,
+		conn6:  c6,
+		users:  1,
+		rxChan: make(chan *pinger.Packet),
+		rxDone: make(chan struct{}),
+	}
+	mp.pinger.SetSockets(c4, c6)
+
+	// This goroutine depends on rxChan and no need to add it to workgroup
+	// It will terminate on channel close
+	go mp.batchProcessPacket(sess)
+
+	// 2 receiver goroutines: separate for IPv4 and IPv6
+	if c4 != nil {
+		sess.wg.Add(1)
+		go mp.batchRecvICMP(sess, pinger.ProtocolIpv4)
+	}
+	if c6 != nil {
+		sess.wg.Add(1)
+		go mp.batchRecvICMP(sess, pinger.ProtocolIpv6)
+	}
+
+	mp.sess = sess
+	return sess, nil
+}
+
+// release closes session, when the last user releases it
+func (mp *MultiPing) release(sess *session) {
+	mp.sessMu.Lock()
+	defer mp.sessMu.Unlock()
+
+	sess.users--
+	if sess.users > 0 {
+		return
+	}
+
+	// Closed connections terminate receivers
+	closeConns(sess.conn4, sess.conn6)
+	sess.wg.Wait()
+	close(sess.rxChan)
+	<-sess.rxDone
+
+	mp.pinger.SetSockets(nil, nil)
+	mp.sess = nil
+}
+
+// register reserves count consecutive sequence numbers for handler and returns the first one.
+// Sequences, which are still used by other handlers, are skipped. Nil handler only reserves sequences.
+func (mp *MultiPing) register(h replyHandler, count int) uint16 {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	first := mp.sequence
+	for i := 0; i < count; i++ {
+		if _, busy := mp.handlers[first+uint16(i)]; busy {
+			// Sequence wrapped around while some long round is still running
+			first += uint16(i) + 1
+			i = -1
+		}
+	}
+
+	for i := 0; i < count; i++ {
+		mp.handlers[first+uint16(i)] = h
+	}
+	mp.sequence = first + uint16(count)
+
+	return first
+}
+
+// maxShortSeqs is count of 8 bit sequence numbers
+const maxShortSeqs = 256
+
+// registerShort reserves count consecutive sequence numbers below 256 for handler and returns the first one.
+// Replies to requests with 8 bit sequence are routed by it, so the same sequence must not be used
+// by other handlers. It fails, when there are no count free sequences below 256.
+func (mp *MultiPing) registerShort(h replyHandler, count int) (uint16, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for first := 0; first+count <= maxShortSeqs; first++ {
+		free := true
+		for i := 0; i < count; i++ {
+			if _, busy := mp.handlers[uint16(first+i)]; busy {
+				first += i
+				free = false
+				break
+			}
+		}
+		if !free {
+			continue
+		}
+
+		for i := 0; i < count; i++ {
+			mp.handlers[uint16(first+i)] = h
+		}
+		return uint16(first), true
+	}
+	return 0, false
+}
+
+// unregister stops routing replies with count sequences starting from first
+func (mp *MultiPing) unregister(first uint16, count int) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for i := 0; i < count; i++ {
+		delete(mp.handlers, first+uint16(i))
+	}
+}
+
+// handler returns reply handler for sequence
+func (mp *MultiPing) handler(seq uint16) (replyHandler, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	h, ok := mp.handlers[seq]
+	return h, ok && h != nil
+}
+
+// registerPort routes ICMP errors about UDP datagrams from source port to h
+func (mp *MultiPing) registerPort(port uint16, h replyHandler) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.ports[port] = h
+}
+
+// unregisterPort stops routing ICMP errors about UDP datagrams from source port
+func (mp *MultiPing) unregisterPort(port uint16) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	delete(mp.ports, port)
+}
+
+// portHandler returns handler of ICMP errors about UDP datagrams from source port
+func (mp *MultiPing) portHandler(port uint16) (replyHandler, bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	h, ok := mp.ports[port]
+	return h, ok
+}
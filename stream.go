@@ -0,0 +1,146 @@
+package multiping
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// ProbeKind tells what happened to echo request
+type ProbeKind int
+
+const (
+	ProbeReply     ProbeKind = iota // echo reply received
+	ProbeTimeout                    // no reply within timeout
+	ProbeSendError                  // echo request was not sent
+	ProbeError                      // ICMP error message received instead of reply, Err is *pinger.IcmpError
+	ProbeCorrupted                  // reply received, but its payload differs from the sent one
+	ProbeTruncated                  // reply received, but its payload is shorter than the sent one
+	ProbeForged                     // reply failed authentication, see WithAuthentication. It is counted, but not streamed.
+)
+
+func (k ProbeKind) String() string {
+	switch k {
+	case ProbeReply:
+		return "reply"
+	case ProbeTimeout:
+		return "timeout"
+	case ProbeSendError:
+		return "send error"
+	case ProbeError:
+		return "icmp error"
+	case ProbeCorrupted:
+		return "corrupted"
+	case ProbeTruncated:
+		return "truncated"
+	case ProbeForged:
+		return "forged"
+	}
+	return fmt.Sprintf("ProbeKind(%d)", int(k))
+}
+
+// ProbeResult is an outcome of a single echo request
+type ProbeResult struct {
+	Kind ProbeKind
+	Addr netip.Addr
+	Seq  uint16
+	RTT  time.Duration // round trip time of reply
+	TTL  int           // TTL (hop limit) of reply
+	TOS  int           // TOS (traffic class) of reply, -1 if unknown (probers must set it, 0 is a valid TOS)
+	Time time.Time     // when reply was received, timeout passed or send failed
+	Err  error         // send error or ICMP error
+
+	// Timestamps of ICMP timestamp reply, nil for echo replies
+	Timestamp *pinger.IcmpTimestamp
+
+	// Port state of port probe reply
+	Port PortState
+
+	// Answer of DNS probe, nil for other probes
+	DNS *DNSReply
+
+	// Interface state of ICMP extended echo reply, nil for other replies
+	Extended *pinger.ExtendedEcho
+}
+
+// Stream pings targets in a single round, like PingContext, but instead of
+// collecting statistics it emits result of every echo request as soon as it is known:
+// one per reply (good, corrupted or truncated), per timeout, per ICMP error and per send error. Channel is closed when round ends.
+func (mp *MultiPing) Stream(ctx context.Context, targets []netip.Addr) <-chan ProbeResult {
+	return mp.StreamConfig(ctx, targets, mp.Config())
+}
+
+// StreamConfig is the same as Stream, but uses cfg settings instead of MultiPing fields.
+func (mp *MultiPing) StreamConfig(ctx context.Context, targets []netip.Addr, cfg RoundConfig) <-chan ProbeResult {
+	if cfg.Count < 1 {
+		cfg.Count = 1
+	}
+
+	data := pingdata.NewPingData()
+	data.Add(targets...)
+
+	// Every request produces exactly one result, so sending to channel never blocks
+	events := make(chan ProbeResult, data.Count()*cfg.Count)
+
+	go func() {
+		defer close(events)
+
+		if data.Count() == 0 || ctx.Err() != nil {
+			return
+		}
+
+		// Nothing can be sent, every host gets the same error
+		fail := func(err error) {
+			data.Iterate(func(addr netip.Addr, _ *pingdata.PingStats) {
+				events <- ProbeResult{Kind: ProbeSendError, Addr: addr, Time: time.Now(), Err: err}
+			})
+		}
+
+		if err := mp.check(&cfg); err != nil {
+			fail(err)
+			return
+		}
+
+		sess, err := mp.acquire()
+		if err != nil {
+			fail(fmt.Errorf("%w: %v", ErrSocketSetup, err))
+			return
+		}
+		defer mp.release(sess)
+
+		r := newRound(ctx, mp, sess, data, cfg)
+		defer r.cancel()
+		if err := r.setProber(); err != nil {
+			fail(err)
+			return
+		}
+		r.stream(events)
+		r.run()
+	}()
+
+	return events
+}
+
+// stream makes round emit results of every echo request to events
+func (r *round) stream(events chan<- ProbeResult) {
+	r.events = events
+
+	var onFinish func()
+	if r.cfg.FinishEarly {
+		onFinish = r.cancel
+	}
+	r.probes = newProbeQueue(onFinish, func(p *probe) {
+		r.emit(ProbeResult{Kind: ProbeTimeout, Addr: p.addr, Seq: p.seq, Time: p.deadline})
+	})
+}
+
+// emit sends result to stream, if round is streaming
+func (r *round) emit(res ProbeResult) {
+	if r.events != nil {
+		r.events <- res
+	}
+}
@@ -0,0 +1,119 @@
+package multiping
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+)
+
+func TestStream(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.Count = 3
+	mp.Interval = 20 * time.Millisecond
+	mp.Timeout = 200 * time.Millisecond
+
+	localhost := netip.MustParseAddr("127.0.0.1")
+	targets := []netip.Addr{localhost, {}}
+
+	replies := make(map[uint16]bool)
+	errs := 0
+	for res := range mp.Stream(context.Background(), targets) {
+		switch res.Kind {
+		case ProbeReply:
+			if res.Addr != localhost || res.RTT <= 0 || res.TTL <= 0 || res.Time.IsZero() {
+				t.Errorf("Invalid reply %+v", res)
+			}
+			if replies[res.Seq] {
+				t.Errorf("Duplicate reply event %+v", res)
+			}
+			replies[res.Seq] = true
+		case ProbeSendError:
+			if res.Err == nil {
+				t.Errorf("Send error without error %+v", res)
+			}
+			errs++
+		default:
+			t.Errorf("Unexpected event %s: %+v", res.Kind, res)
+		}
+	}
+
+	if len(replies) != 3 || errs != 3 {
+		t.Errorf("Unexpected event count: replies=%d errors=%d", len(replies), errs)
+	}
+}
+
+func TestStreamTimeout(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.Timeout = 100 * time.Millisecond
+
+	data := []netip.Addr{netip.MustParseAddr("127.0.0.1")}
+	r := &round{cfg: mp.Config()}
+	events := make(chan ProbeResult, 2)
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	defer r.cancel()
+	r.stream(events)
+
+	// Request without reply must time out on its own deadline
+	start := time.Now()
+	r.probes.add(data[0], 1, start.Add(mp.Timeout))
+	r.probes.close()
+
+	select {
+	case res := <-events:
+		if res.Kind != ProbeTimeout || res.Addr != data[0] || res.Seq != 1 {
+			t.Errorf("Unexpected event %+v", res)
+		}
+		if time.Since(start) < mp.Timeout {
+			t.Errorf("Timeout event is too early")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Timeout event missing")
+	}
+	r.probes.stop()
+}
+
+func TestStreamLateReply(t *testing.T) {
+	mp, err := New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	mp.Timeout = 50 * time.Millisecond
+
+	addr := netip.MustParseAddr("127.0.0.1")
+	data := pingdata.NewPingData()
+	data.Add(addr)
+	stats, _ := data.Get(addr)
+	stats.Send(7)
+
+	events := make(chan ProbeResult, 2)
+	r := newRound(context.Background(), mp, nil, data, mp.Config())
+	defer r.cancel()
+	r.stream(events)
+
+	r.probes.add(addr, 7, time.Now().Add(mp.Timeout))
+	r.probes.close()
+	if res := <-events; res.Kind != ProbeTimeout || res.Seq != 7 {
+		t.Fatalf("Unexpected event %+v", res)
+	}
+
+	// Reply arrives after its request timed out, but before round ends
+	r.handleResult(ProbeResult{Kind: ProbeReply, Addr: addr, Seq: 7, RTT: 2 * mp.Timeout, TOS: -1})
+	select {
+	case res := <-events:
+		t.Errorf("Late reply emitted %+v", res)
+	default:
+	}
+	if stats.Received() != 0 || r.summary.Received != 0 || r.summary.Unmatched != 1 {
+		t.Errorf("Late reply counted: rx %d, summary %+v", stats.Received(), r.summary)
+	}
+	r.probes.stop()
+}
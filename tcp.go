@@ -0,0 +1,163 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// PingTCP probes hosts in data with TCP connections to port instead of echo requests.
+// Both accepted and refused connections count as replies, PingStats.Refused counts the latter.
+// Use RoundConfig.TCP with ProbeOptions.Port for per host ports.
+func (mp *MultiPing) PingTCP(ctx context.Context, data *pingdata.PingData, port int) (RoundSummary, error) {
+	cfg := mp.Config()
+	cfg.TCP = true
+	cfg.Probe.Port = port
+	return mp.PingConfig(ctx, data, cfg)
+}
+
+// probePort returns port of TCP or UDP probe to addr
+func (cfg *RoundConfig) probePort(addr netip.Addr) (uint16, error) {
+	port := cfg.probe(addr).Port
+	if port == 0 {
+		return 0, fmt.Errorf("%w: no port for %s", ErrInvalidOption, addr)
+	}
+	return uint16(port), nil
+}
+
+// connectProber probes with non-blocking TCP connect or connected UDP socket, which need no privileges.
+// Every probe is a goroutine waiting for connection or answer. Its result waits in results,
+// until Receive returns packet of probe and Match picks the result.
+type connectProber struct {
+	mp      *MultiPing
+	cfg     *RoundConfig
+	network string // "tcp" or "udp"
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup // connecting goroutines
+	packets chan *pinger.Packet
+
+	mu      sync.Mutex
+	results map[probeKey]ProbeResult
+}
+
+func (mp *MultiPing) newConnectProber(cfg *RoundConfig, network string) *connectProber {
+	p := &connectProber{
+		mp:      mp,
+		cfg:     cfg,
+		network: network,
+		packets: make(chan *pinger.Packet),
+		results: make(map[probeKey]ProbeResult),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	return p
+}
+
+func (p *connectProber) Prepare(addr netip.Addr, seq uint16, opts ProbeOptions) (*pinger.Packet, error) {
+	if _, err := p.cfg.probePort(addr); err != nil {
+		return nil, err
+	}
+	return &pinger.Packet{Addr: addr, Seq: seq}, nil
+}
+
+func (p *connectProber) Send(pkt *pinger.Packet) error {
+	port, err := p.cfg.probePort(pkt.Addr)
+	if err != nil {
+		return err
+	}
+
+	p.wg.Add(1)
+	go p.connect(pkt.Addr, port, pkt.Seq)
+	return nil
+}
+
+// connect probes addr port and reports, whether port answered or refused
+func (p *connectProber) connect(addr netip.Addr, port, seq uint16) {
+	defer p.wg.Done()
+
+	res := ProbeResult{Kind: ProbeReply, Addr: addr, Seq: seq, TOS: -1}
+	var ok bool
+	if p.network == "udp" {
+		res.Port, res.RTT, ok = p.exchangeUDP(netip.AddrPortFrom(addr, port))
+	} else {
+		res.Port, res.RTT, ok = p.connectTCP(netip.AddrPortFrom(addr, port))
+	}
+	if !ok {
+		// Timeout or unreachable host, probe is lost
+		return
+	}
+	res.Time = time.Now()
+
+	p.mu.Lock()
+	p.results[probeKey{addr: addr, seq: seq}] = res
+	p.mu.Unlock()
+
+	select {
+	case p.packets <- &pinger.Packet{Addr: addr, Seq: seq}:
+	case <-p.ctx.Done():
+	}
+}
+
+// connectTCP connects to dst and returns, whether connection was accepted or refused
+func (p *connectProber) connectTCP(dst netip.AddrPort) (PortState, time.Duration, bool) {
+	d := net.Dialer{Timeout: p.cfg.Timeout}
+	if source := p.mp.source(dst.Addr()); source.IsValid() {
+		d.LocalAddr = &net.TCPAddr{IP: source.AsSlice()}
+	}
+
+	start := time.Now()
+	conn, err := d.DialContext(p.ctx, "tcp", dst.String())
+	rtt := time.Since(start)
+	switch {
+	case err == nil:
+		// Reset connection instead of closing it, so that no TIME_WAIT is left behind
+		if tc, ok := conn.(*net.TCPConn); ok {
+			tc.SetLinger(0)
+		}
+		conn.Close()
+		return PortOpen, rtt, true
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return PortRefused, rtt, true
+	}
+	return PortNone, 0, false
+}
+
+func (p *connectProber) Receive() (*pinger.Packet, error) {
+	select {
+	case pkt := <-p.packets:
+		return pkt, nil
+	case <-p.ctx.Done():
+		return nil, ErrProberClosed
+	}
+}
+
+func (p *connectProber) Match(pkt *pinger.Packet) (ProbeResult, bool) {
+	key := probeKey{addr: pkt.Addr, seq: pkt.Seq}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res, ok := p.results[key]
+	delete(p.results, key)
+	return res, ok
+}
+
+func (p *connectProber) Close() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// source returns source address set with WithSource for addr family
+func (mp *MultiPing) source(addr netip.Addr) netip.Addr {
+	if addr.Is4() {
+		return mp.listen4.Source
+	}
+	return mp.listen6.Source
+}
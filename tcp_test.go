@@ -0,0 +1,116 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+func TestPingTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	open := netip.MustParseAddr("127.0.0.1")
+	closed := netip.MustParseAddr("127.0.0.2")
+
+	for _, privileged := range []bool{false, true} {
+		mp, err := NewWithOptions(WithPrivileged(privileged), WithTimeout(500*time.Millisecond))
+		if err != nil {
+			t.Logf("Privileged %v is not available: %s", privileged, err)
+			continue
+		}
+
+		data := pingdata.NewPingData()
+		data.Add(open, closed)
+
+		cfg := mp.Config()
+		cfg.TCP = true
+		cfg.Count = 2
+		cfg.Interval = 100 * time.Millisecond
+		cfg.Probe.Port = ln.Addr().(*net.TCPAddr).Port
+		// Nothing listens on discard port
+		cfg.Targets = map[netip.Addr]ProbeOptions{closed: {Port: 9}}
+		summary, err := mp.PingConfig(context.Background(), data, cfg)
+		if err != nil {
+			t.Fatalf("Privileged %v: TCP ping failed: %s", privileged, err)
+		}
+		if summary.Received != 4 || summary.Refused != 2 {
+			t.Errorf("Privileged %v: invalid summary %+v", privileged, summary)
+		}
+
+		stats, _ := data.Get(open)
+		if stats.Loss() != 0 || stats.Refused() != 0 {
+			t.Errorf("Privileged %v: open port loss %f, refused %d", privileged, stats.Loss(), stats.Refused())
+		}
+		stats, _ = data.Get(closed)
+		if stats.Loss() != 0 || stats.Refused() != 2 {
+			t.Errorf("Privileged %v: closed port loss %f, refused %d", privileged, stats.Loss(), stats.Refused())
+		}
+
+		cfg.Targets = nil
+		cfg.Probe.Port = 0
+		if _, err = mp.PingConfig(context.Background(), data, cfg); !errors.Is(err, ErrPartialSend) {
+			t.Errorf("Privileged %v: expected send failure without port, got %v", privileged, err)
+		}
+	}
+}
+
+func TestTCPChecksum(t *testing.T) {
+	src, dst := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")
+	p := &synProber{
+		cfg:     &RoundConfig{Probe: ProbeOptions{Port: 443}},
+		port:    40000,
+		tag:     0xabcd,
+		sources: map[netip.Addr]netip.Addr{dst: src},
+		sent:    make(map[probeKey]time.Time),
+	}
+
+	const seq = 3131
+	pkt, err := p.Prepare(dst, seq, ProbeOptions{})
+	if err != nil {
+		t.Fatalf("SYN prepare failed: %s", err)
+	}
+	// Checksum of segment with checksum is zero
+	if sum := tcpChecksum(src, dst, pkt.Bytes); sum != 0 {
+		t.Errorf("Invalid checksum, verification gives %#x", sum)
+	}
+
+	// Turn SYN into SYN-ACK from target
+	b := append([]byte(nil), pkt.Bytes...)
+	b[0], b[1], b[2], b[3] = b[2], b[3], b[0], b[1]
+	copy(b[8:12], b[4:8])
+	b[11]++
+	b[13] = tcpFlagSYN | tcpFlagACK
+	reply, ok := p.Match(&pinger.Packet{Addr: dst, Bytes: b})
+	if !ok || reply.Seq != seq || reply.Port != PortOpen {
+		t.Errorf("SYN-ACK not matched: %+v", reply)
+	}
+
+	b[13] = tcpFlagRST | tcpFlagACK
+	if reply, ok = p.Match(&pinger.Packet{Addr: dst, Bytes: b}); !ok || reply.Port != PortRefused {
+		t.Errorf("RST not matched: %+v", reply)
+	}
+
+	b[8] ^= 0xff
+	if _, ok = p.Match(&pinger.Packet{Addr: dst, Bytes: b}); ok {
+		t.Error("Segment of other connection matched")
+	}
+}
@@ -0,0 +1,295 @@
+package multiping
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+const (
+	tcpHeaderLen = 20
+	tcpSynLen    = tcpHeaderLen + 4 // with MSS option
+	tcpMSS       = 1460
+	tcpWindow    = 64240
+
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagACK = 0x10
+
+	protocolTCP = 6
+
+	// Local ports are chosen from Linux default ephemeral port range
+	minLocalPort = 32768
+	maxLocalPort = 60999
+)
+
+// synProber sends raw TCP SYN segments and parses SYN-ACK and RST answers.
+// Kernel has no socket of probe local port, so it resets connections answered with SYN-ACK itself.
+type synProber struct {
+	cfg   *RoundConfig
+	conn4 *net.IPConn
+	conn6 *net.IPConn
+	port  uint16 // local port of probes
+	tag   uint16 // upper half of sequence numbers of probes, lower half is probe seq
+
+	// Locks maps below
+	mu      sync.Mutex
+	sent    map[probeKey]time.Time
+	sources map[netip.Addr]netip.Addr // source addresses of probes by destination
+
+	packets chan *pinger.Packet
+	done    chan struct{}
+	wg      sync.WaitGroup // reader goroutines
+}
+
+func (mp *MultiPing) newSYNProber(cfg *RoundConfig, sess *session) (*synProber, error) {
+	p := &synProber{
+		cfg:     cfg,
+		port:    uint16(minLocalPort + rand.Intn(maxLocalPort-minLocalPort+1)),
+		tag:     uint16(rand.Uint32()),
+		sent:    make(map[probeKey]time.Time),
+		sources: make(map[netip.Addr]netip.Addr),
+		packets: make(chan *pinger.Packet),
+		done:    make(chan struct{}),
+	}
+
+	// Sockets of the same families as ICMP session
+	var err error
+	if sess.conn4 != nil {
+		if p.conn4, err = listenTCP("ip4:tcp", mp.listen4.Source); err != nil {
+			return nil, err
+		}
+	}
+	if sess.conn6 != nil {
+		if p.conn6, err = listenTCP("ip6:tcp", mp.listen6.Source); err != nil {
+			if p.conn4 != nil {
+				p.conn4.Close()
+			}
+			return nil, err
+		}
+	}
+
+	for _, conn := range []*net.IPConn{p.conn4, p.conn6} {
+		if conn != nil {
+			p.wg.Add(1)
+			go p.read(conn)
+		}
+	}
+	return p, nil
+}
+
+// listenTCP opens raw TCP socket bound to source, if it is valid
+func listenTCP(network string, source netip.Addr) (*net.IPConn, error) {
+	addr := &net.IPAddr{}
+	if source.IsValid() {
+		addr.IP = source.AsSlice()
+	}
+	return net.ListenIP(network, addr)
+}
+
+func (p *synProber) Prepare(addr netip.Addr, seq uint16, opts ProbeOptions) (*pinger.Packet, error) {
+	port, err := p.cfg.probePort(addr)
+	if err != nil {
+		return nil, err
+	}
+	src, err := p.source(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, tcpSynLen)
+	binary.BigEndian.PutUint16(b[0:], p.port)
+	binary.BigEndian.PutUint16(b[2:], port)
+	binary.BigEndian.PutUint32(b[4:], uint32(p.tag)<<16|uint32(seq))
+	b[12] = tcpSynLen / 4 << 4
+	b[13] = tcpFlagSYN
+	binary.BigEndian.PutUint16(b[14:], tcpWindow)
+	// MSS option
+	b[20], b[21] = 2, 4
+	binary.BigEndian.PutUint16(b[22:], tcpMSS)
+	binary.BigEndian.PutUint16(b[16:], tcpChecksum(src, addr, b))
+
+	pkt := &pinger.Packet{Proto: pinger.ProtocolIpv4, Addr: addr, Seq: seq, Bytes: b, Len: len(b)}
+	if addr.Is6() {
+		pkt.Proto = pinger.ProtocolIpv6
+	}
+	return pkt, nil
+}
+
+// source returns source address of packets to addr. It is needed for TCP checksum.
+func (p *synProber) source(addr netip.Addr) (netip.Addr, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if src, ok := p.sources[addr]; ok {
+		return src, nil
+	}
+
+	conn := p.conn4
+	if addr.Is6() {
+		conn = p.conn6
+	}
+	if conn == nil {
+		return netip.Addr{}, ErrUnsupportedFamily
+	}
+	src := netip.Addr{}
+	if local, ok := conn.LocalAddr().(*net.IPAddr); ok && local.IP != nil && !local.IP.IsUnspecified() {
+		src, _ = netip.AddrFromSlice(local.IP)
+	} else {
+		// Connected UDP socket gets source address of route to addr, nothing is sent
+		udp, err := net.DialUDP("udp", nil, net.UDPAddrFromAddrPort(netip.AddrPortFrom(addr, 9)))
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		src = udp.LocalAddr().(*net.UDPAddr).AddrPort().Addr()
+		udp.Close()
+	}
+
+	src = src.Unmap()
+	p.sources[addr] = src
+	return src, nil
+}
+
+func (p *synProber) Send(pkt *pinger.Packet) error {
+	conn := p.conn4
+	if pkt.Addr.Is6() {
+		conn = p.conn6
+	}
+	if conn == nil {
+		return ErrUnsupportedFamily
+	}
+
+	p.mu.Lock()
+	p.sent[probeKey{addr: pkt.Addr, seq: pkt.Seq}] = time.Now()
+	p.mu.Unlock()
+
+	_, err := conn.WriteToIP(pkt.Bytes, &net.IPAddr{IP: pkt.Addr.AsSlice(), Zone: pkt.Addr.Zone()})
+	return err
+}
+
+// read reads TCP segments received by raw socket. Raw socket gets all TCP segments
+// of host, so segments not addressed to probe local port are dropped here.
+func (p *synProber) read(conn *net.IPConn) {
+	defer p.wg.Done()
+
+	proto := pinger.ProtocolIpv4
+	if conn == p.conn6 {
+		proto = pinger.ProtocolIpv6
+	}
+	b := make([]byte, 512)
+	for {
+		// IPv4 header is stripped
+		n, from, err := conn.ReadFromIP(b)
+		if err != nil {
+			return
+		}
+		addr, ok := netip.AddrFromSlice(from.IP)
+		if !ok || n < tcpHeaderLen || binary.BigEndian.Uint16(b[2:]) != p.port {
+			continue
+		}
+
+		pkt := &pinger.Packet{Proto: proto, Addr: addr.Unmap(), Bytes: append([]byte(nil), b[:n]...), Len: n}
+		select {
+		case p.packets <- pkt:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *synProber) Receive() (*pinger.Packet, error) {
+	select {
+	case pkt := <-p.packets:
+		return pkt, nil
+	case <-p.done:
+		return nil, ErrProberClosed
+	}
+}
+
+// Match checks, whether TCP segment answers probe
+func (p *synProber) Match(pkt *pinger.Packet) (ProbeResult, bool) {
+	b := pkt.Bytes
+	if len(b) < tcpHeaderLen || binary.BigEndian.Uint16(b[2:]) != p.port {
+		return ProbeResult{}, false
+	}
+	if port, err := p.cfg.probePort(pkt.Addr); err != nil || binary.BigEndian.Uint16(b[0:]) != port {
+		return ProbeResult{}, false
+	}
+
+	flags := b[13]
+	if flags&tcpFlagACK == 0 {
+		return ProbeResult{}, false
+	}
+	// Answer acknowledges SYN, so ack is probe sequence number + 1
+	isn := binary.BigEndian.Uint32(b[8:]) - 1
+	if uint16(isn>>16) != p.tag {
+		return ProbeResult{}, false
+	}
+
+	res := ProbeResult{Kind: ProbeReply, Addr: pkt.Addr, Seq: uint16(isn), TOS: -1}
+	switch {
+	case flags&tcpFlagRST != 0:
+		res.Port = PortRefused
+	case flags&tcpFlagSYN != 0:
+		res.Port = PortOpen
+	default:
+		return ProbeResult{}, false
+	}
+
+	key := probeKey{addr: pkt.Addr, seq: res.Seq}
+	p.mu.Lock()
+	if sent, ok := p.sent[key]; ok {
+		res.RTT = time.Since(sent)
+		delete(p.sent, key)
+	}
+	p.mu.Unlock()
+	return res, true
+}
+
+func (p *synProber) Close() {
+	close(p.done)
+	if p.conn4 != nil {
+		p.conn4.Close()
+	}
+	if p.conn6 != nil {
+		p.conn6.Close()
+	}
+	p.wg.Wait()
+}
+
+// tcpChecksum returns checksum of TCP segment b from src to dst
+func tcpChecksum(src, dst netip.Addr, b []byte) uint16 {
+	var pseudo []byte
+	if dst.Is4() {
+		pseudo = make([]byte, 0, 12)
+		pseudo = append(pseudo, src.AsSlice()...)
+		pseudo = append(pseudo, dst.AsSlice()...)
+		pseudo = append(pseudo, 0, protocolTCP)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(b)))
+	} else {
+		pseudo = make([]byte, 0, 40)
+		pseudo = append(pseudo, src.AsSlice()...)
+		pseudo = append(pseudo, dst.AsSlice()...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(b)))
+		pseudo = append(pseudo, 0, 0, 0, protocolTCP)
+	}
+
+	var sum uint32
+	for _, part := range [][]byte{pseudo, b} {
+		for i := 0; i+1 < len(part); i += 2 {
+			sum += uint32(binary.BigEndian.Uint16(part[i:]))
+		}
+		if len(part)%2 == 1 {
+			sum += uint32(part[len(part)-1]) << 8
+		}
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
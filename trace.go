@@ -0,0 +1,256 @@
+package multiping
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// Traceroute defaults
+const (
+	DefaultMaxHops       = 30
+	DefaultTraceInterval = 50 * time.Millisecond
+)
+
+// Hop is a router on the path to traceroute target
+type Hop struct {
+	TTL  int
+	Addr netip.Addr    // router or target, which replied. Not valid, when nobody replied
+	RTT  time.Duration // round trip time of probe
+}
+
+// Trace is a path to traceroute target
+type Trace struct {
+	Hops    []Hop // hops by TTL starting with 1, up to the last replied hop
+	Reached bool  // target replied, it is the last hop
+}
+
+// TraceConfig holds traceroute settings
+type TraceConfig struct {
+	// MaxHops is the largest TTL probed. Default is DefaultMaxHops.
+	MaxHops int
+
+	// Interval between probes with successive TTL. Default is DefaultTraceInterval.
+	Interval time.Duration
+
+	// Timeout to wait for replies after the last probe. Default is MultiPing Timeout.
+	Timeout time.Duration
+}
+
+// tracer collects hops of traceroute round
+type tracer struct {
+	paths map[netip.Addr]*Trace
+	sent  map[probeKey]time.Time
+	stats *pingdata.PathData // per hop statistics, when pinging paths
+}
+
+// Traceroute finds paths to all targets at once. Echo requests with TTL (hop limit) 1, 2, ...
+// are sent to every target, routers answer them with Time Exceeded and targets with echo reply.
+// Probes with the same TTL are sent to all targets in a single batch. Targets are not probed
+// with larger TTL, when they have already replied.
+// ICMP errors are received only by raw sockets, so it requires privileged mode.
+func (mp *MultiPing) Traceroute(ctx context.Context, targets []netip.Addr, cfg TraceConfig) (map[netip.Addr]*Trace, error) {
+	data := pingdata.NewPingData()
+	data.Add(targets...)
+
+	t, _, err := mp.trace(ctx, data, nil, cfg)
+	if t == nil {
+		return nil, err
+	}
+	return t.paths, err
+}
+
+// PingPaths probes every hop on paths to hosts in data once and adds results to per hop statistics,
+// like mtr does. Call it in a loop to see where on the path loss starts.
+// Hosts are probed up to their TTL known from previous calls or up to cfg.MaxHops.
+// It requires privileged mode.
+func (mp *MultiPing) PingPaths(ctx context.Context, data *pingdata.PathData, cfg TraceConfig) (RoundSummary, error) {
+	hosts := pingdata.NewPingData()
+	data.Iterate(func(addr netip.Addr, _ *pingdata.PathStats) {
+		hosts.Add(addr)
+	})
+
+	_, summary, err := mp.trace(ctx, hosts, data, cfg)
+	return summary, err
+}
+
+// trace runs traceroute round for hosts in data. If stats is not nil, per hop statistics are collected.
+func (mp *MultiPing) trace(ctx context.Context, data *pingdata.PingData, stats *pingdata.PathData, cfg TraceConfig) (*tracer, RoundSummary, error) {
+	if mp.Mode() != ModePrivileged {
+		return nil, RoundSummary{}, ErrPrivilegedOnly
+	}
+
+	if cfg.MaxHops == 0 {
+		cfg.MaxHops = DefaultMaxHops
+	}
+	if cfg.MaxHops < 1 || cfg.MaxHops > 255 {
+		return nil, RoundSummary{}, fmt.Errorf("%w: max hops %d is out of range [1, 255]", ErrInvalidOption, cfg.MaxHops)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultTraceInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = mp.Timeout
+	}
+
+	t := &tracer{
+		paths: make(map[netip.Addr]*Trace),
+		sent:  make(map[probeKey]time.Time),
+		stats: stats,
+	}
+	data.Iterate(func(addr netip.Addr, _ *pingdata.PingStats) {
+		t.paths[addr] = &Trace{}
+	})
+	if data.Count() == 0 {
+		return t, RoundSummary{}, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, RoundSummary{}, err
+	}
+
+	sess, err := mp.acquire()
+	if err != nil {
+		return nil, RoundSummary{}, fmt.Errorf("%w: %v", ErrSocketSetup, err)
+	}
+	defer mp.release(sess)
+
+	r := newRound(ctx, mp, sess, data, RoundConfig{
+		Timeout:     cfg.Timeout,
+		Count:       cfg.MaxHops,
+		Interval:    cfg.Interval,
+		FinishEarly: true,
+	})
+	r.trace = t
+	defer r.cancel()
+	if err := r.setProber(); err != nil {
+		return nil, RoundSummary{}, err
+	}
+	r.run()
+
+	return t, r.summary, r.err(ctx)
+}
+
+// ttl returns TTL of traceroute probe with sequence seq
+func (r *round) ttl(seq uint16) int {
+	return int(seq-r.sequence) + 1
+}
+
+// reached checks if traceroute target has replied with TTL smaller than ttl
+// in this round or in previous rounds of PingPaths. Must be called with r.mu locked
+func (t *tracer) reached(addr netip.Addr, ttl int) bool {
+	path := t.paths[addr]
+	if path.Reached && len(path.Hops) < ttl {
+		return true
+	}
+
+	if t.stats != nil {
+		if ps, ok := t.stats.Get(addr); ok && ps.Reached() > 0 && ps.Reached() < ttl {
+			return true
+		}
+	}
+	return false
+}
+
+// send registers probe in per hop statistics. Must be called with r.mu locked
+func (t *tracer) send(addr netip.Addr, ttl int, seq uint16) {
+	if t.stats == nil {
+		return
+	}
+	if ps, ok := t.stats.Get(addr); ok {
+		ps.Hop(ttl).Send(seq)
+	}
+}
+
+// duplicate registers duplicate reply in per hop statistics. Must be called with r.mu locked
+func (t *tracer) duplicate(addr netip.Addr, ttl int, seq uint16) {
+	if t.stats == nil {
+		return
+	}
+	if ps, ok := t.stats.Get(addr); ok && ttl <= ps.Len() {
+		ps.Hop(ttl).Recv(seq, 0)
+	}
+}
+
+// record registers reply of router or target in per hop statistics. Must be called with r.mu locked
+func (t *tracer) record(addr netip.Addr, ttl int, seq uint16, rtt time.Duration, router netip.Addr, reached bool) {
+	if t.stats == nil {
+		return
+	}
+	ps, ok := t.stats.Get(addr)
+	if !ok {
+		return
+	}
+
+	if reached {
+		if ps.Reached() == 0 || ttl < ps.Reached() {
+			ps.SetReached(ttl)
+		}
+	} else if ttl == ps.Reached() {
+		// Router replied instead of target, path became longer
+		ps.SetReached(0)
+	}
+
+	hop := ps.Hop(ttl)
+	if hop.Recv(seq, rtt) {
+		hop.SetRouter(router)
+	}
+}
+
+// traceReply registers reply to traceroute probe. Must be called with r.mu locked
+func (r *round) traceReply(res ProbeResult) {
+	addr, router, rtt := res.Addr, res.Addr, res.RTT
+	icmpErr, isErr := res.Err.(*pinger.IcmpError)
+	if res.Kind == ProbeError && isErr {
+		router = icmpErr.Router
+	}
+	reached := res.Kind != ProbeError
+
+	path, known := r.trace.paths[addr]
+	if !known {
+		r.summary.Unmatched++
+		return
+	}
+
+	ttl := r.ttl(res.Seq)
+	key := probeKey{addr: addr, seq: res.Seq}
+	sent, ok := r.trace.sent[key]
+	if !ok {
+		// Already answered, count it as duplicate
+		r.summary.Unmatched++
+		r.trace.duplicate(addr, ttl, res.Seq)
+		return
+	}
+	delete(r.trace.sent, key)
+
+	if !reached {
+		// Error carries no timestamp
+		rtt = time.Since(sent)
+		r.summary.Errors++
+	} else {
+		r.summary.Received++
+	}
+	if r.probes != nil {
+		r.probes.done(addr, res.Seq)
+	}
+
+	if path.Reached && ttl > len(path.Hops) {
+		// Target has already replied to smaller TTL
+		return
+	}
+	if reached {
+		path.Reached = true
+		if len(path.Hops) > ttl {
+			path.Hops = path.Hops[:ttl]
+		}
+	}
+	for len(path.Hops) < ttl {
+		path.Hops = append(path.Hops, Hop{TTL: len(path.Hops) + 1})
+	}
+	path.Hops[ttl-1] = Hop{TTL: ttl, Addr: router, RTT: rtt}
+	r.trace.record(addr, ttl, res.Seq, rtt, router, reached)
+}
@@ -0,0 +1,70 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+)
+
+func TestTraceroute(t *testing.T) {
+	mp, err := New(true)
+	if err != nil {
+		t.Skipf("Privileged mode is not available: %s", err)
+	}
+
+	local := netip.MustParseAddr("127.0.0.1")
+	paths, err := mp.Traceroute(context.Background(), []netip.Addr{local}, TraceConfig{MaxHops: 5})
+	if err != nil {
+		t.Fatalf("Traceroute failed: %s", err)
+	}
+
+	path := paths[local]
+	if path == nil || !path.Reached || len(path.Hops) != 1 {
+		t.Fatalf("Invalid path to localhost %+v", path)
+	}
+	if hop := path.Hops[0]; hop.TTL != 1 || hop.Addr != local || hop.RTT <= 0 {
+		t.Errorf("Invalid hop %+v", hop)
+	}
+
+	mp, err = New(false)
+	if err != nil {
+		t.Fatalf("Multiping constructor failed %s", err)
+	}
+	if _, err = mp.Traceroute(context.Background(), []netip.Addr{local}, TraceConfig{}); !errors.Is(err, ErrPrivilegedOnly) {
+		t.Errorf("Unprivileged traceroute must fail, got %v", err)
+	}
+}
+
+func TestPingPaths(t *testing.T) {
+	mp, err := New(true)
+	if err != nil {
+		t.Skipf("Privileged mode is not available: %s", err)
+	}
+
+	local := netip.MustParseAddr("127.0.0.1")
+	data := pingdata.NewPathData()
+	data.Add(local)
+
+	for i := 0; i < 3; i++ {
+		summary, err := mp.PingPaths(context.Background(), data, TraceConfig{MaxHops: 5})
+		if err != nil {
+			t.Fatalf("PingPaths failed: %s", err)
+		}
+		// Path is known after the first round, only the host itself is probed then
+		if i > 0 && summary.Sent != 1 {
+			t.Errorf("Round %d sent %d probes", i, summary.Sent)
+		}
+	}
+
+	path, _ := data.Get(local)
+	if path.Reached() != 1 || path.Len() != 1 {
+		t.Fatalf("Invalid path to localhost: reached %d len %d", path.Reached(), path.Len())
+	}
+	hop := path.Hop(1)
+	if hop.Router() != local || hop.Received() != 3 || hop.Loss() != 0 {
+		t.Errorf("Invalid hop statistics %s", hop.String())
+	}
+}
@@ -0,0 +1,269 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+// PingUDP probes hosts in data with UDP datagrams to port instead of echo requests.
+// Hosts answer closed port with ICMP port unreachable, which counts as refused reply,
+// see PingStats.Refused. Datagram answered by application counts as open port reply.
+// Use RoundConfig.UDP with ProbeOptions.Port for per host ports.
+func (mp *MultiPing) PingUDP(ctx context.Context, data *pingdata.PingData, port int) (RoundSummary, error) {
+	cfg := mp.Config()
+	cfg.UDP = true
+	cfg.Probe.Port = port
+	return mp.PingConfig(ctx, data, cfg)
+}
+
+// udpPayload is payload of UDP probes
+var udpPayload = []byte("multiping")
+
+// exchangeUDP sends datagram to dst with connected socket and waits for answer.
+// Kernel reports ICMP port unreachable to connected socket as refused connection.
+func (p *connectProber) exchangeUDP(dst netip.AddrPort) (PortState, time.Duration, bool) {
+	var local *net.UDPAddr
+	if source := p.mp.source(dst.Addr()); source.IsValid() {
+		local = &net.UDPAddr{IP: source.AsSlice()}
+	}
+	conn, err := net.DialUDP("udp", local, net.UDPAddrFromAddrPort(dst))
+	if err != nil {
+		return PortNone, 0, false
+	}
+	defer conn.Close()
+
+	// Unblock read, when prober is closed
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-p.ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(p.cfg.Timeout))
+	if _, err = conn.Write(udpPayload); err == nil {
+		_, err = conn.Read(make([]byte, 512))
+	}
+	rtt := time.Since(start)
+	switch {
+	case err == nil:
+		return PortOpen, rtt, true
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return PortRefused, rtt, true
+	}
+	return PortNone, 0, false
+}
+
+// udpProber sends UDP datagrams from one socket per probe sequence and matches ICMP port unreachable
+// errors received by raw ICMP socket back to probes by quoted UDP ports. Answers of applications
+// are read from probe sockets and received with local port of socket set.
+type udpProber struct {
+	mp  *MultiPing
+	cfg *RoundConfig
+
+	// Locks maps below
+	mu    sync.Mutex
+	socks map[sockKey]*udpSocket
+	ports map[uint16]*udpSocket // sockets by local port
+	sent  map[probeKey]time.Time
+
+	packets chan *pinger.Packet
+	parsed  parsedStats // ICMP errors parsed by session
+	done    chan struct{}
+	wg      sync.WaitGroup // reader goroutines
+}
+
+// sockKey identifies probe socket
+type sockKey struct {
+	seq uint16
+	is6 bool
+}
+
+// udpSocket sends probes with the same sequence
+type udpSocket struct {
+	conn *net.UDPConn
+	seq  uint16
+	port uint16 // local port
+}
+
+func (mp *MultiPing) newUDPProber(cfg *RoundConfig) *udpProber {
+	return &udpProber{
+		mp:      mp,
+		cfg:     cfg,
+		socks:   make(map[sockKey]*udpSocket),
+		ports:   make(map[uint16]*udpSocket),
+		sent:    make(map[probeKey]time.Time),
+		packets: make(chan *pinger.Packet),
+		done:    make(chan struct{}),
+	}
+}
+
+func (p *udpProber) Prepare(addr netip.Addr, seq uint16, opts ProbeOptions) (*pinger.Packet, error) {
+	if _, err := p.cfg.probePort(addr); err != nil {
+		return nil, err
+	}
+	if _, err := p.socket(addr, seq); err != nil {
+		return nil, err
+	}
+	return &pinger.Packet{Addr: addr, Seq: seq, Bytes: udpPayload}, nil
+}
+
+// socket returns socket of probes with sequence seq to addr family. It is opened on first use.
+func (p *udpProber) socket(addr netip.Addr, seq uint16) (*udpSocket, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := sockKey{seq: seq, is6: addr.Is6()}
+	if sock, ok := p.socks[key]; ok {
+		return sock, nil
+	}
+
+	network := "udp4"
+	if addr.Is6() {
+		network = "udp6"
+	}
+	local := &net.UDPAddr{}
+	if source := p.mp.source(addr); source.IsValid() {
+		local.IP = source.AsSlice()
+	}
+	conn, err := net.ListenUDP(network, local)
+	if err != nil {
+		return nil, err
+	}
+
+	port := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+	sock := &udpSocket{conn: conn, seq: seq, port: port}
+	p.socks[key] = sock
+	p.ports[port] = sock
+	p.mp.registerPort(port, p)
+
+	p.wg.Add(1)
+	go p.read(sock)
+	return sock, nil
+}
+
+func (p *udpProber) Send(pkt *pinger.Packet) error {
+	port, err := p.cfg.probePort(pkt.Addr)
+	if err != nil {
+		return err
+	}
+	sock, err := p.socket(pkt.Addr, pkt.Seq)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.sent[probeKey{addr: pkt.Addr, seq: pkt.Seq}] = time.Now()
+	p.mu.Unlock()
+
+	_, err = sock.conn.WriteToUDPAddrPort(pkt.Bytes, netip.AddrPortFrom(pkt.Addr, port))
+	return err
+}
+
+// read reads application answers to probes of sock
+func (p *udpProber) read(sock *udpSocket) {
+	defer p.wg.Done()
+
+	b := make([]byte, 512)
+	for {
+		n, from, err := sock.conn.ReadFromUDPAddrPort(b)
+		if err != nil {
+			return
+		}
+		addr := from.Addr().Unmap()
+		if port, err := p.cfg.probePort(addr); err != nil || from.Port() != port {
+			continue
+		}
+		p.handleReply(&pinger.Packet{Addr: addr, Port: sock.port, Bytes: append([]byte(nil), b[:n]...), Len: n}, pinger.IcmpStats{})
+	}
+}
+
+// handleReply passes application answer or ICMP error routed by session to Receive
+func (p *udpProber) handleReply(pkt *pinger.Packet, stats pinger.IcmpStats) {
+	if pkt.Port == 0 {
+		p.parsed.put(pkt, stats)
+	}
+	select {
+	case p.packets <- pkt:
+	case <-p.done:
+		p.parsed.take(pkt)
+	}
+}
+
+func (p *udpProber) Receive() (*pinger.Packet, error) {
+	select {
+	case pkt := <-p.packets:
+		return pkt, nil
+	case <-p.done:
+		return nil, ErrProberClosed
+	}
+}
+
+// Match matches application answer to probe by socket and ICMP error by quoted ports.
+// Port unreachable is a refused reply, other errors (e.g. host unreachable) are returned as ProbeError.
+func (p *udpProber) Match(pkt *pinger.Packet) (ProbeResult, bool) {
+	res := ProbeResult{Kind: ProbeReply, Addr: pkt.Addr, TOS: -1, Port: PortOpen}
+	port := pkt.Port
+	if port == 0 {
+		pingStats, ok := p.parsed.take(pkt)
+		if !ok {
+			pingStats = p.mp.pinger.ParsePacket(pkt)
+		}
+		icmpErr := pingStats.Error
+		if icmpErr == nil || !icmpErr.UDP {
+			return ProbeResult{}, false
+		}
+		if dst, err := p.cfg.probePort(icmpErr.Dest); err != nil || icmpErr.Port != dst {
+			return ProbeResult{}, false
+		}
+
+		res.Addr, port = icmpErr.Dest, icmpErr.SourcePort
+		if icmpErr.PortUnreachable() {
+			res.Port = PortRefused
+		} else {
+			res = ProbeResult{Kind: ProbeError, Addr: icmpErr.Dest, Err: icmpErr}
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sock, ok := p.ports[port]
+	if !ok {
+		return ProbeResult{}, false
+	}
+	res.Seq = sock.seq
+	key := probeKey{addr: res.Addr, seq: sock.seq}
+	if sent, ok := p.sent[key]; ok {
+		if res.Kind == ProbeReply {
+			res.RTT = time.Since(sent)
+		}
+		delete(p.sent, key)
+	}
+	return res, true
+}
+
+func (p *udpProber) Close() {
+	close(p.done)
+
+	p.mu.Lock()
+	for port, sock := range p.ports {
+		p.mp.unregisterPort(port)
+		sock.conn.Close()
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
@@ -0,0 +1,109 @@
+package multiping
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+
+	"github.com/drgkaleda/go-multiping/pingdata"
+	"github.com/drgkaleda/go-multiping/pinger"
+)
+
+func TestPingUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %s", err)
+	}
+	defer pc.Close()
+	go func() {
+		b := make([]byte, 512)
+		for {
+			n, from, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(b[:n], from)
+		}
+	}()
+
+	open := netip.MustParseAddr("127.0.0.1")
+	closed := netip.MustParseAddr("127.0.0.2")
+
+	for _, privileged := range []bool{false, true} {
+		mp, err := NewWithOptions(WithPrivileged(privileged), WithTimeout(500*time.Millisecond))
+		if err != nil {
+			t.Logf("Privileged %v is not available: %s", privileged, err)
+			continue
+		}
+
+		data := pingdata.NewPingData()
+		data.Add(open, closed)
+
+		cfg := mp.Config()
+		cfg.UDP = true
+		cfg.Count = 2
+		cfg.Interval = 100 * time.Millisecond
+		cfg.Probe.Port = 33434
+		cfg.Targets = map[netip.Addr]ProbeOptions{open: {Port: pc.LocalAddr().(*net.UDPAddr).Port}}
+		summary, err := mp.PingConfig(context.Background(), data, cfg)
+		if err != nil {
+			t.Fatalf("Privileged %v: UDP ping failed: %s", privileged, err)
+		}
+		if summary.Received != 4 || summary.Refused != 2 {
+			t.Errorf("Privileged %v: invalid summary %+v", privileged, summary)
+		}
+
+		stats, _ := data.Get(closed)
+		if stats.Loss() != 0 || stats.Refused() != 2 || stats.Rtt() <= 0 {
+			t.Errorf("Privileged %v: closed port loss %f, refused %d, rtt %s",
+				privileged, stats.Loss(), stats.Refused(), stats.Rtt())
+		}
+	}
+}
+
+func TestUDPMatchError(t *testing.T) {
+	mp, err := New(true)
+	if err != nil {
+		t.Skipf("Privileged mode is not available: %s", err)
+	}
+	host := netip.MustParseAddr("198.51.100.1")
+	router := netip.MustParseAddr("192.0.2.1")
+	cfg := &RoundConfig{Probe: ProbeOptions{Port: 33434}}
+	p := mp.newUDPProber(cfg)
+	p.ports[40000] = &udpSocket{port: 40000, seq: 7}
+
+	// ICMP error quoting IPv4 header and UDP header of datagram from port 40000 to 33434
+	icmpError := func(code int) *pinger.Packet {
+		data := make([]byte, 20+8)
+		data[0] = 0x45
+		data[9] = 17
+		copy(data[16:20], host.AsSlice())
+		data[20], data[21], data[22], data[23] = 0x9c, 0x40, 0x82, 0x9a
+
+		msg := icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: code, Body: &icmp.DstUnreach{Data: data}}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			t.Fatalf("Icmp marshal %s", err)
+		}
+		return &pinger.Packet{Proto: pinger.ProtocolIpv4, Bytes: b, Len: len(b), Addr: router}
+	}
+
+	res, ok := p.Match(icmpError(3))
+	if !ok || res.Kind != ProbeReply || res.Port != PortRefused || res.Addr != host || res.Seq != 7 {
+		t.Errorf("Port unreachable not matched: %+v", res)
+	}
+
+	// Host unreachable
+	res, ok = p.Match(icmpError(1))
+	var icmpErr *pinger.IcmpError
+	if !ok || res.Kind != ProbeError || res.Addr != host || res.Seq != 7 || !errors.As(res.Err, &icmpErr) ||
+		icmpErr.Code != 1 || icmpErr.Router != router {
+		t.Errorf("Host unreachable not matched: %+v", res)
+	}
+}